@@ -0,0 +1,646 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHost is the address of the docker daemon used when DOCKER_HOST
+// is not set.
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// APIClient is a Client that talks to the docker daemon's HTTP API
+// directly, rather than exec'ing the docker CLI.
+type APIClient struct {
+	// BaseURL is the URL against which API paths are resolved.
+	BaseURL string
+
+	// HTTPClient sends the requests to the daemon.
+	HTTPClient *http.Client
+
+	// dial connects to the daemon, for requests that take over the
+	// connection once the daemon replies.
+	dial func(ctx context.Context) (net.Conn, error)
+}
+
+// NewAPIClient returns a new APIClient for the daemon identified by
+// the DOCKER_HOST environment variable, falling back to DefaultHost.
+func NewAPIClient() (*APIClient, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = DefaultHost
+	}
+	return NewAPIClientForHost(host)
+}
+
+// NewAPIClientForHost returns a new APIClient for the daemon at the
+// given address. Both "unix://" and "tcp://" addresses are supported.
+func NewAPIClientForHost(host string) (*APIClient, error) {
+	parts := strings.SplitN(host, "://", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid docker host %q", host)
+	}
+	network, addr := parts[0], parts[1]
+
+	baseURL := "http://" + addr
+	switch network {
+	case "unix":
+		// The host part of the URL is ignored when dialing a socket.
+		baseURL = "http://docker"
+	case "tcp":
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q in docker host %q", network, host)
+	}
+
+	dial := func(ctx context.Context) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, addr)
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dial(ctx)
+		},
+	}
+	api := &APIClient{
+		BaseURL: baseURL,
+		HTTPClient: &http.Client{
+			Transport: transport,
+		},
+		dial: dial,
+	}
+	return api, nil
+}
+
+// Run runs a new docker container with the given info.
+func (api *APIClient) Run(args RunArgs) (string, error) {
+	return api.RunContext(context.Background(), args)
+}
+
+// RunContext is like Run, but gives up when the context is done.
+func (api *APIClient) RunContext(ctx context.Context, args RunArgs) (string, error) {
+	if err := args.Validate(); err != nil {
+		return "", err
+	}
+
+	path := "/containers/create"
+	if args.Name != "" {
+		path += "?" + url.Values{"name": {args.Name}}.Encode()
+	}
+
+	req, err := newCreateRequest(args)
+	if err != nil {
+		return "", err
+	}
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err = api.do(ctx, "POST", path, req, &created)
+	if IsImageNotFound(err) {
+		// docker run pulls an image that isn't present locally, so
+		// do the same.
+		if err := api.pull(ctx, args.Image); err != nil {
+			return "", err
+		}
+		err = api.do(ctx, "POST", path, req, &created)
+	}
+	if err != nil {
+		return "", redactError(err, args.secrets())
+	}
+
+	if err := api.do(ctx, "POST", containerPath(created.ID, "start"), nil, nil); err != nil {
+		return "", redactError(err, args.secrets())
+	}
+	return created.ID, nil
+}
+
+// pull fetches the image from its registry.
+func (api *APIClient) pull(ctx context.Context, image string) error {
+	name, tag := splitImageTag(image)
+	query := url.Values{"fromImage": {name}}
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+	path := "/images/create?" + query.Encode()
+	resp, err := api.send(ctx, "POST", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The daemon streams progress messages, and reports a failure part
+	// way through as a message with an error.
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		err := decoder.Decode(&msg)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return contextError("docker POST "+path, ctx.Err())
+			}
+			return fmt.Errorf("can't decode response from docker POST %s: %s", path, err)
+		}
+		if msg.Error != "" {
+			err := fmt.Errorf("docker POST %s failed: %s", path, msg.Error)
+			if kind := classifyMessage(msg.Error); kind != nil {
+				return &Error{Kind: kind, Err: err}
+			}
+			return err
+		}
+	}
+}
+
+// splitImageTag splits the image reference into the repository and
+// the tag to pull. A reference by digest is pulled as it is, and one
+// without a tag is pulled as "latest", as docker run does (older
+// daemons would otherwise pull every tag).
+func splitImageTag(image string) (string, string) {
+	if strings.Contains(image, "@") {
+		return image, ""
+	}
+	// A colon before the last slash separates a registry's port.
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+	return image, "latest"
+}
+
+// Inspect gets info about the given container ID (or name).
+func (api *APIClient) Inspect(id string) (*Info, error) {
+	return api.InspectContext(context.Background(), id)
+}
+
+// InspectContext is like Inspect, but gives up when the context is done.
+func (api *APIClient) InspectContext(ctx context.Context, id string) (*Info, error) {
+	var info Info
+	if err := api.do(ctx, "GET", containerPath(id, "json"), nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// InspectMany gets info about each of the given container IDs (or
+// names). There is one result per ID, in the same order.
+func (api *APIClient) InspectMany(ids ...string) ([]InspectResult, error) {
+	var results []InspectResult
+	for _, id := range ids {
+		info, err := api.Inspect(id)
+		if err != nil && !IsNotFound(err) {
+			return nil, err
+		}
+		results = append(results, InspectResult{
+			ID:   id,
+			Info: info,
+			Err:  err,
+		})
+	}
+	return results, nil
+}
+
+// Stop stops the identified container.
+func (api *APIClient) Stop(id string) error {
+	return api.StopContext(context.Background(), id)
+}
+
+// StopContext is like Stop, but gives up when the context is done.
+func (api *APIClient) StopContext(ctx context.Context, id string) error {
+	return api.do(ctx, "POST", containerPath(id, "stop"), nil, nil)
+}
+
+// Remove removes the identified container.
+func (api *APIClient) Remove(id string) error {
+	return api.RemoveContext(context.Background(), id)
+}
+
+// RemoveContext is like Remove, but gives up when the context is done.
+func (api *APIClient) RemoveContext(ctx context.Context, id string) error {
+	return api.do(ctx, "DELETE", containerPath(id, ""), nil, nil)
+}
+
+// StopWithArgs stops the identified container as directed by the args,
+// and reports how it came to exit.
+func (api *APIClient) StopWithArgs(id string, args StopArgs) (*StopResult, error) {
+	query := url.Values{}
+	if args.Timeout > 0 {
+		query.Set("t", formatSeconds(args.Timeout))
+	}
+	if args.Signal != "" {
+		query.Set("signal", args.Signal)
+	}
+	path := containerPath(id, "stop")
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	if err := api.do(context.Background(), "POST", path, nil, nil); err != nil {
+		return nil, err
+	}
+
+	info, err := api.Inspect(id)
+	if err != nil {
+		return nil, err
+	}
+	return newStopResult(info), nil
+}
+
+// RemoveWithArgs removes the identified container as directed by the
+// args.
+func (api *APIClient) RemoveWithArgs(id string, args RemoveArgs) error {
+	query := url.Values{}
+	if args.Force {
+		query.Set("force", "1")
+	}
+	if args.Volumes {
+		query.Set("v", "1")
+	}
+	if args.Link {
+		query.Set("link", "1")
+	}
+	path := containerPath(id, "")
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	if err := api.do(context.Background(), "DELETE", path, nil, nil); err != nil {
+		if args.IgnoreNotFound && IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Start starts the identified (stopped) container.
+func (api *APIClient) Start(id string) error {
+	return api.do(context.Background(), "POST", containerPath(id, "start"), nil, nil)
+}
+
+// Restart stops and then starts the identified container, giving it
+// the timeout to stop before it is killed.
+func (api *APIClient) Restart(id string, timeout time.Duration) error {
+	query := url.Values{"t": {formatSeconds(timeout)}}
+	path := containerPath(id, "restart") + "?" + query.Encode()
+	return api.do(context.Background(), "POST", path, nil, nil)
+}
+
+// Kill sends the signal (e.g. "SIGINT") to the main process of the
+// identified container. If no signal is given then SIGKILL is sent.
+func (api *APIClient) Kill(id string, signal string) error {
+	path := containerPath(id, "kill")
+	if signal != "" {
+		path += "?" + url.Values{"signal": {signal}}.Encode()
+	}
+	return api.do(context.Background(), "POST", path, nil, nil)
+}
+
+// Pause suspends all processes in the identified container.
+func (api *APIClient) Pause(id string) error {
+	return api.do(context.Background(), "POST", containerPath(id, "pause"), nil, nil)
+}
+
+// Unpause resumes all processes in the identified container.
+func (api *APIClient) Unpause(id string) error {
+	return api.do(context.Background(), "POST", containerPath(id, "unpause"), nil, nil)
+}
+
+// Logs returns the output of the identified container (stdout and
+// stderr combined), as directed by the args. The caller must close the
+// returned reader, which stops any streaming.
+func (api *APIClient) Logs(id string, args LogsArgs) (io.ReadCloser, error) {
+	// The output is only multiplexed if the container has no TTY.
+	info, err := api.Inspect(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	path := containerPath(id, "logs") + "?" + logsQuery(args).Encode()
+	resp, err := api.send(ctx, "GET", path, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	body := resp.Body
+	if info.Config == nil || !info.Config.Tty {
+		body = newDemuxReader(resp.Body)
+	}
+	return &cancelReader{ReadCloser: body, cancel: cancel}, nil
+}
+
+// Exec runs a command in the identified (running) container. A non-zero
+// exit code from the command is reported in the result rather than as
+// an error.
+func (api *APIClient) Exec(id string, args ExecArgs) (*ExecResult, error) {
+	ctx := context.Background()
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := api.do(ctx, "POST", containerPath(id, "exec"), newExecRequest(args), &created); err != nil {
+		return nil, err
+	}
+
+	// The connection must be hijacked to send the command's stdin.
+	path := "/exec/" + url.PathEscape(created.ID)
+	conn, r, err := api.hijack(ctx, "POST", path+"/start", execStartRequest{Tty: args.TTY})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if args.Stdin != nil {
+		go func() {
+			io.Copy(conn, args.Stdin)
+			closeWrite(conn)
+		}()
+	}
+
+	var stdout, stderr bytes.Buffer
+	if args.TTY {
+		_, err = io.Copy(&stdout, r)
+	} else {
+		err = demultiplex(r, &stdout, &stderr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read output of docker exec %s: %s", id, err)
+	}
+
+	var inspected struct {
+		ExitCode int
+	}
+	if err := api.do(ctx, "GET", path+"/json", nil, &inspected); err != nil {
+		return nil, err
+	}
+	result := &ExecResult{
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		ExitCode: inspected.ExitCode,
+	}
+	return result, nil
+}
+
+// Wait blocks until the identified container stops, and then reports
+// how its main process exited.
+func (api *APIClient) Wait(ctx context.Context, id string) (*ExitStatus, error) {
+	var waited struct {
+		StatusCode int
+	}
+	if err := api.do(ctx, "POST", containerPath(id, "wait"), nil, &waited); err != nil {
+		return nil, err
+	}
+
+	info, err := api.InspectContext(ctx, id)
+	if IsNotFound(err) {
+		// The container was removed as soon as it stopped.
+		return newExitStatus(waited.StatusCode, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newExitStatus(waited.StatusCode, info)
+}
+
+// WaitUntil blocks until the identified container meets the condition,
+// or the context is done.
+func (api *APIClient) WaitUntil(ctx context.Context, id string, cond WaitCondition) error {
+	return waitUntil(ctx, id, cond, func(ctx context.Context) (*containerStatus, error) {
+		var inspected struct {
+			State containerStatus
+		}
+		if err := api.do(ctx, "GET", containerPath(id, "json"), nil, &inspected); err != nil {
+			return nil, err
+		}
+		return &inspected.State, nil
+	})
+}
+
+// List returns summaries of the containers that match the args.
+func (api *APIClient) List(args ListArgs) ([]ContainerSummary, error) {
+	query, err := listQuery(args)
+	if err != nil {
+		return nil, err
+	}
+	path := "/containers/json"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var entries []listEntry
+	if err := api.do(context.Background(), "GET", path, nil, &entries); err != nil {
+		return nil, err
+	}
+	var summaries []ContainerSummary
+	for _, entry := range entries {
+		summaries = append(summaries, entry.summary())
+	}
+	return summaries, nil
+}
+
+// FindByLabels returns summaries of all the containers (running or
+// not) that have every one of the labels.
+func (api *APIClient) FindByLabels(labels map[string]string) ([]ContainerSummary, error) {
+	if len(labels) == 0 {
+		return nil, errNoLabels
+	}
+	return api.List(ListArgs{All: true, Labels: labels})
+}
+
+// listQuery converts the ListArgs into the equivalent API query.
+func listQuery(args ListArgs) (url.Values, error) {
+	query := url.Values{}
+	if args.All {
+		query.Set("all", "1")
+	}
+	filters := make(map[string][]string)
+	for _, filter := range args.filters() {
+		filters[filter[0]] = append(filters[filter[0]], filter[1])
+	}
+	if len(filters) > 0 {
+		data, err := json.Marshal(filters)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("filters", string(data))
+	}
+	return query, nil
+}
+
+// logsQuery converts the LogsArgs into the equivalent API query.
+func logsQuery(args LogsArgs) url.Values {
+	query := url.Values{
+		"stdout": {"1"},
+		"stderr": {"1"},
+	}
+	if args.Tail > 0 {
+		query.Set("tail", strconv.Itoa(args.Tail))
+	}
+	if !args.Since.IsZero() {
+		query.Set("since", formatUnixTime(args.Since))
+	}
+	if !args.Until.IsZero() {
+		query.Set("until", formatUnixTime(args.Until))
+	}
+	if args.Timestamps {
+		query.Set("timestamps", "1")
+	}
+	if args.Follow {
+		query.Set("follow", "1")
+	}
+	return query
+}
+
+// formatUnixTime converts the time into the fractional Unix timestamp
+// that the API expects.
+func formatUnixTime(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// do sends a request to the daemon. The request body, if any, is
+// encoded as JSON, as is the response body decoded into result.
+func (api *APIClient) do(ctx context.Context, method, path string, body, result interface{}) error {
+	resp, err := api.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		if ctx.Err() != nil {
+			return contextError("docker "+method+" "+path, ctx.Err())
+		}
+		return fmt.Errorf("can't decode response from docker %s %s: %s", method, path, err)
+	}
+	return nil
+}
+
+// send sends a request to the daemon, returning an error for any
+// unsuccessful response. The caller must close the response body.
+func (api *APIClient) send(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, api.BaseURL+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := api.HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, contextError("docker "+method+" "+path, ctx.Err())
+		}
+		return nil, classifyDialError(err)
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		defer resp.Body.Close()
+		return nil, newAPIError(method, path, resp)
+	}
+	return resp, nil
+}
+
+// hijack sends a request to the daemon, asking to take over the
+// connection once the daemon replies. The connection is returned, along
+// with a reader for whatever the daemon sends after the response
+// headers. The caller must close the connection.
+func (api *APIClient) hijack(ctx context.Context, method, path string, body interface{}) (net.Conn, *bufio.Reader, error) {
+	if api.dial == nil {
+		return nil, nil, fmt.Errorf("docker %s %s: client cannot hijack connections", method, path)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequest(method, api.BaseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	conn, err := api.dial(ctx)
+	if err != nil {
+		return nil, nil, classifyDialError(err)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	// Older daemons reply with 200 OK rather than switching protocols.
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		defer conn.Close()
+		return nil, nil, newAPIError(method, path, resp)
+	}
+	return conn, r, nil
+}
+
+// closeWrite shuts down the writing side of the connection, if it
+// supports that, so that the daemon sees the end of the input.
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface {
+		CloseWrite() error
+	}); ok {
+		cw.CloseWrite()
+	}
+}
+
+// newAPIError converts an unsuccessful response into a classified
+// error. Newer daemons send a JSON message while older ones send
+// plain text.
+func newAPIError(method, path string, resp *http.Response) error {
+	data, _ := ioutil.ReadAll(resp.Body)
+	var msg struct {
+		Message string `json:"message"`
+	}
+	text := string(bytes.TrimSpace(data))
+	if err := json.Unmarshal(data, &msg); err == nil && msg.Message != "" {
+		text = msg.Message
+	}
+	err := fmt.Errorf("docker %s %s failed with status %d: %s", method, path, resp.StatusCode, text)
+	return classifyAPIError(resp.StatusCode, text, err)
+}
+
+// containerPath returns the API path for the identified container,
+// with the given sub-resource (if any) appended.
+func containerPath(id, resource string) string {
+	path := "/containers/" + url.PathEscape(id)
+	if resource != "" {
+		path += "/" + resource
+	}
+	return path
+}
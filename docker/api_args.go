@@ -0,0 +1,265 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"strconv"
+	"strings"
+)
+
+// createRequest is the body of a "create container" API request.
+type createRequest struct {
+	Image        string
+	Cmd          []string            `json:",omitempty"`
+	Entrypoint   []string            `json:",omitempty"`
+	WorkingDir   string              `json:",omitempty"`
+	User         string              `json:",omitempty"`
+	Labels       map[string]string   `json:",omitempty"`
+	Env          []string            `json:",omitempty"`
+	ExposedPorts map[string]struct{} `json:",omitempty"`
+	HostConfig   hostConfig
+}
+
+// hostConfig holds the host-specific part of a createRequest.
+type hostConfig struct {
+	Binds             []string                 `json:",omitempty"`
+	Mounts            []mount                  `json:",omitempty"`
+	PortBindings      map[string][]portBinding `json:",omitempty"`
+	Memory            int64                    `json:",omitempty"`
+	MemorySwap        int64                    `json:",omitempty"`
+	MemoryReservation int64                    `json:",omitempty"`
+	CPUShares         int64                    `json:"CpuShares,omitempty"`
+	CPUPeriod         int64                    `json:"CpuPeriod,omitempty"`
+	CPUQuota          int64                    `json:"CpuQuota,omitempty"`
+	NanoCPUs          int64                    `json:"NanoCpus,omitempty"`
+	CpusetCpus        string                   `json:",omitempty"`
+	PidsLimit         int64                    `json:",omitempty"`
+	BlkioWeight       uint16                   `json:",omitempty"`
+	OomKillDisable    bool                     `json:",omitempty"`
+	RestartPolicy     *restartPolicy           `json:",omitempty"`
+}
+
+// restartPolicy is the restart policy in a hostConfig.
+type restartPolicy struct {
+	Name              string
+	MaximumRetryCount int
+}
+
+// mount describes a mount in a hostConfig.
+type mount struct {
+	Type          string
+	Source        string `json:",omitempty"`
+	Target        string
+	ReadOnly      bool           `json:",omitempty"`
+	BindOptions   *bindOptions   `json:",omitempty"`
+	VolumeOptions *volumeOptions `json:",omitempty"`
+	TmpfsOptions  *tmpfsOptions  `json:",omitempty"`
+}
+
+// bindOptions holds the options for a bind mount.
+type bindOptions struct {
+	Propagation string
+}
+
+// volumeOptions holds the options for a volume mount.
+type volumeOptions struct {
+	NoCopy bool
+}
+
+// tmpfsOptions holds the options for a tmpfs mount. The daemon passes
+// the mode on to the mount in octal, so it holds unix mode bits (with
+// 01000 as the sticky bit, not os.ModeSticky).
+type tmpfsOptions struct {
+	SizeBytes int64  `json:",omitempty"`
+	Mode      uint32 `json:",omitempty"`
+}
+
+// newMount converts the MountAssignment, which must have a type, into
+// the equivalent API mount.
+func newMount(ma MountAssignment) mount {
+	m := mount{
+		Type:     string(ma.Type),
+		Source:   ma.External,
+		Target:   ma.Internal,
+		ReadOnly: ma.ReadOnly,
+	}
+	if ma.Propagation != "" {
+		m.BindOptions = &bindOptions{Propagation: ma.Propagation}
+	}
+	if ma.NoCopy {
+		m.VolumeOptions = &volumeOptions{NoCopy: true}
+	}
+	if ma.TmpfsSize != 0 || ma.TmpfsMode != 0 {
+		m.TmpfsOptions = &tmpfsOptions{
+			SizeBytes: int64(ma.TmpfsSize),
+			Mode:      ma.TmpfsMode,
+		}
+	}
+	return m
+}
+
+// portBinding identifies the host side of a published port.
+type portBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string
+}
+
+// newCreateRequest converts the RunArgs into the equivalent API request.
+func newCreateRequest(ra RunArgs) (createRequest, error) {
+	cmd, err := ra.command()
+	if err != nil {
+		return createRequest{}, err
+	}
+	env, err := ra.env()
+	if err != nil {
+		return createRequest{}, err
+	}
+	if err := ra.Resources.Validate(); err != nil {
+		return createRequest{}, err
+	}
+	if err := ra.RestartPolicy.Validate(); err != nil {
+		return createRequest{}, err
+	}
+
+	req := createRequest{
+		Image:      ra.Image,
+		Cmd:        cmd,
+		Env:        env,
+		Entrypoint: ra.Entrypoint,
+		WorkingDir: ra.WorkDir,
+		User:       ra.User,
+		Labels:     ra.Labels,
+		HostConfig: hostConfig{
+			Memory:            int64(ra.Resources.Memory),
+			MemorySwap:        int64(ra.Resources.MemorySwap),
+			MemoryReservation: int64(ra.Resources.MemoryReservation),
+			CPUShares:         ra.Resources.CPUShares,
+			CPUPeriod:         ra.Resources.CPUPeriod.Microseconds(),
+			CPUQuota:          ra.Resources.CPUQuota.Microseconds(),
+			NanoCPUs:          ra.Resources.CPUs.nanoCPUs(),
+			CpusetCpus:        ra.Resources.CpusetCPUs,
+			PidsLimit:         ra.Resources.PidsLimit,
+			BlkioWeight:       ra.Resources.BlkioWeight,
+			OomKillDisable:    ra.Resources.OOMKillDisable,
+		},
+	}
+
+	if ra.RestartPolicy.Name != "" {
+		req.HostConfig.RestartPolicy = &restartPolicy{
+			Name:              string(ra.RestartPolicy.Name),
+			MaximumRetryCount: ra.RestartPolicy.MaxRetries,
+		}
+	}
+
+	for _, pa := range ra.Ports {
+		if req.ExposedPorts == nil {
+			req.ExposedPorts = make(map[string]struct{})
+			req.HostConfig.PortBindings = make(map[string][]portBinding)
+		}
+		for _, p := range pa.expand() {
+			port := strconv.Itoa(p.Internal) + "/" + p.protocol()
+			binding := portBinding{
+				HostIP: p.HostIP,
+			}
+			if p.External != 0 {
+				binding.HostPort = strconv.Itoa(p.External)
+			}
+			req.ExposedPorts[port] = struct{}{}
+			req.HostConfig.PortBindings[port] = append(req.HostConfig.PortBindings[port], binding)
+		}
+	}
+
+	for _, m := range ra.Mounts {
+		if m.Flag() == "--mount" {
+			req.HostConfig.Mounts = append(req.HostConfig.Mounts, newMount(m))
+			continue
+		}
+		req.HostConfig.Binds = append(req.HostConfig.Binds, m.String())
+	}
+
+	return req, nil
+}
+
+// execRequest is the body of a "create exec instance" API request.
+type execRequest struct {
+	AttachStdin  bool
+	AttachStdout bool
+	AttachStderr bool
+	Tty          bool
+	Cmd          []string
+	Env          []string `json:",omitempty"`
+	User         string   `json:",omitempty"`
+	WorkingDir   string   `json:",omitempty"`
+}
+
+// newExecRequest converts the ExecArgs into the equivalent API request.
+func newExecRequest(ea ExecArgs) execRequest {
+	return execRequest{
+		AttachStdin:  ea.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          ea.TTY,
+		Cmd:          ea.Cmd,
+		Env:          sortedEnv(ea.Env),
+		User:         ea.User,
+		WorkingDir:   ea.WorkDir,
+	}
+}
+
+// execStartRequest is the body of a "start exec instance" API request.
+type execStartRequest struct {
+	Detach bool
+	Tty    bool
+}
+
+// listEntry is a single container in the response to a "list
+// containers" API request.
+type listEntry struct {
+	ID     string `json:"Id"`
+	Names  []string
+	Image  string
+	State  string
+	Status string
+	Labels map[string]string
+	Ports  []struct {
+		IP          string
+		PrivatePort int
+		PublicPort  int
+		Type        string
+	}
+}
+
+// summary converts the entry into a ContainerSummary.
+func (entry listEntry) summary() ContainerSummary {
+	summary := ContainerSummary{
+		ID:     entry.ID,
+		Image:  entry.Image,
+		State:  entry.State,
+		Status: entry.Status,
+		Labels: entry.Labels,
+	}
+	if summary.State == "" {
+		// Older versions of the API do not report the state.
+		summary.State = stateFromStatus(entry.Status)
+	}
+	for _, name := range entry.Names {
+		summary.Names = append(summary.Names, strings.TrimPrefix(name, "/"))
+	}
+
+	var ports publishedPorts
+	for _, port := range entry.Ports {
+		if port.PublicPort == 0 {
+			// The port is exposed but not published.
+			continue
+		}
+		ports.add(PortAssignment{
+			HostIP:   port.IP,
+			External: port.PublicPort,
+			Internal: port.PrivatePort,
+			Protocol: port.Type,
+		})
+	}
+	summary.Ports = ports
+	return summary
+}
@@ -0,0 +1,1077 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju-process-docker/docker"
+)
+
+var _ docker.Client = (*docker.APIClient)(nil)
+
+var _ = gc.Suite(&apiSuite{})
+
+type apiSuite struct {
+	fakeDaemonSuite
+}
+
+// fakeDaemonSuite is embedded by suites whose tests talk to a fake
+// daemon, and stops each daemon when the test that started it ends.
+type fakeDaemonSuite struct {
+	testing.CleanupSuite
+}
+
+// newAPIClient starts a fake daemon listening on a unix socket and
+// returns a client connected to it. The fake daemon replies to each
+// request in turn with the given responses.
+func (s *fakeDaemonSuite) newAPIClient(c *gc.C, responses ...apiResponse) (*docker.APIClient, *fakeDaemon) {
+	fake := &fakeDaemon{
+		responses: responses,
+	}
+	server := httptest.NewUnstartedServer(fake)
+	listener, err := net.Listen("unix", filepath.Join(c.MkDir(), "docker.sock"))
+	c.Assert(err, jc.ErrorIsNil)
+	server.Listener = listener
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+
+	client, err := docker.NewAPIClientForHost("unix://" + listener.Addr().String())
+	c.Assert(err, jc.ErrorIsNil)
+	return client, fake
+}
+
+func (s *apiSuite) TestNewAPIClientDefault(c *gc.C) {
+	s.PatchEnvironment("DOCKER_HOST", "")
+
+	client, err := docker.NewAPIClient()
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(client.BaseURL, gc.Equals, "http://docker")
+}
+
+func (s *apiSuite) TestNewAPIClientTCP(c *gc.C) {
+	s.PatchEnvironment("DOCKER_HOST", "tcp://10.0.0.1:2375")
+
+	client, err := docker.NewAPIClient()
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(client.BaseURL, gc.Equals, "http://10.0.0.1:2375")
+}
+
+func (apiSuite) TestNewAPIClientForHostInvalid(c *gc.C) {
+	_, err := docker.NewAPIClientForHost("/var/run/docker.sock")
+
+	c.Check(err, gc.ErrorMatches, `invalid docker host "/var/run/docker.sock"`)
+}
+
+func (apiSuite) TestNewAPIClientForHostUnsupported(c *gc.C) {
+	_, err := docker.NewAPIClientForHost("ssh://spam")
+
+	c.Check(err, gc.ErrorMatches, `unsupported protocol "ssh" in docker host "ssh://spam"`)
+}
+
+func (s *apiSuite) TestRunOkay(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusCreated, body: `{"Id":"eggs","Warnings":null}`},
+		apiResponse{status: http.StatusNoContent},
+	)
+
+	args := docker.RunArgs{
+		Name:    "spam",
+		Image:   "my-spam",
+		Command: "do something",
+		EnvVars: map[string]string{
+			"FOO": "bar",
+		},
+		Ports: []docker.PortAssignment{{
+			External: 8080,
+			Internal: 80,
+			Protocol: "tcp",
+		}},
+		Mounts: []docker.MountAssignment{{
+			External: "/srv/data",
+			Internal: "/data",
+			Mode:     "ro",
+		}},
+	}
+	id, err := client.Run(args)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(id, gc.Equals, "eggs")
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[0].method, gc.Equals, "POST")
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/create?name=spam")
+	c.Check(fake.requests[0].body, jc.DeepEquals, map[string]interface{}{
+		"Image": "my-spam",
+		"Cmd":   []interface{}{"do", "something"},
+		"Env":   []interface{}{"FOO=bar"},
+		"ExposedPorts": map[string]interface{}{
+			"80/tcp": map[string]interface{}{},
+		},
+		"HostConfig": map[string]interface{}{
+			"Binds": []interface{}{"/srv/data:/data:ro"},
+			"PortBindings": map[string]interface{}{
+				"80/tcp": []interface{}{
+					map[string]interface{}{"HostIp": "", "HostPort": "8080"},
+				},
+			},
+		},
+	})
+	c.Check(fake.requests[1].method, gc.Equals, "POST")
+	c.Check(fake.requests[1].uri, gc.Equals, "/containers/eggs/start")
+}
+
+func (s *apiSuite) TestRunMinimal(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusCreated, body: `{"Id":"eggs"}`},
+		apiResponse{status: http.StatusNoContent},
+	)
+
+	id, err := client.Run(docker.RunArgs{Image: "my-spam"})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(id, gc.Equals, "eggs")
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/create")
+	c.Check(fake.requests[0].body, jc.DeepEquals, map[string]interface{}{
+		"Image":      "my-spam",
+		"HostConfig": map[string]interface{}{},
+	})
+}
+
+func (s *apiSuite) TestRunEnvOrdering(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusCreated, body: `{"Id":"eggs"}`},
+		apiResponse{status: http.StatusNoContent},
+	)
+
+	_, err := client.Run(docker.RunArgs{
+		Image:   "my-spam",
+		EnvVars: map[string]string{"FOO": "bar", "BAZ": "qux", "SPAM": "eggs"},
+		Env:     docker.EnvList{{Name: "FOO", Value: "override"}},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[0].body, jc.DeepEquals, map[string]interface{}{
+		"Image":      "my-spam",
+		"Env":        []interface{}{"BAZ=qux", "FOO=bar", "SPAM=eggs", "FOO=override"},
+		"HostConfig": map[string]interface{}{},
+	})
+}
+
+func (s *apiSuite) TestRunSecretEnv(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusInternalServerError, body: `{"message":"bad env PASSWORD=hunter2"}`},
+	)
+
+	_, err := client.Run(docker.RunArgs{
+		Image: "my-spam",
+		Env:   docker.EnvList{{Name: "PASSWORD", Value: "hunter2", Secret: true}},
+	})
+
+	c.Check(err, gc.ErrorMatches, "docker POST /containers/create failed with status 500: bad env PASSWORD=<redacted>")
+	c.Assert(fake.requests, gc.HasLen, 1)
+	c.Check(fake.requests[0].body, jc.DeepEquals, map[string]interface{}{
+		"Image":      "my-spam",
+		"Env":        []interface{}{"PASSWORD=hunter2"},
+		"HostConfig": map[string]interface{}{},
+	})
+}
+
+func (s *apiSuite) TestRunPortBindings(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusCreated, body: `{"Id":"eggs"}`},
+		apiResponse{status: http.StatusNoContent},
+	)
+
+	_, err := client.Run(docker.RunArgs{
+		Image: "my-spam",
+		Ports: []docker.PortAssignment{
+			{HostIP: "127.0.0.1", Internal: 80},
+			{External: 7000, Internal: 8000, Count: 2, Protocol: "udp"},
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[0].body, jc.DeepEquals, map[string]interface{}{
+		"Image": "my-spam",
+		"ExposedPorts": map[string]interface{}{
+			"80/tcp":   map[string]interface{}{},
+			"8000/udp": map[string]interface{}{},
+			"8001/udp": map[string]interface{}{},
+		},
+		"HostConfig": map[string]interface{}{
+			"PortBindings": map[string]interface{}{
+				"80/tcp": []interface{}{
+					map[string]interface{}{"HostIp": "127.0.0.1", "HostPort": ""},
+				},
+				"8000/udp": []interface{}{
+					map[string]interface{}{"HostIp": "", "HostPort": "7000"},
+				},
+				"8001/udp": []interface{}{
+					map[string]interface{}{"HostIp": "", "HostPort": "7001"},
+				},
+			},
+		},
+	})
+}
+
+func (s *apiSuite) TestRunQuotedCommand(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusCreated, body: `{"Id":"eggs"}`},
+		apiResponse{status: http.StatusNoContent},
+	)
+
+	_, err := client.Run(docker.RunArgs{
+		Image:   "my-spam",
+		Command: `sh -c "echo hello world"`,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[0].body, jc.DeepEquals, map[string]interface{}{
+		"Image":      "my-spam",
+		"Cmd":        []interface{}{"sh", "-c", "echo hello world"},
+		"HostConfig": map[string]interface{}{},
+	})
+}
+
+func (s *apiSuite) TestRunEntrypoint(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusCreated, body: `{"Id":"eggs"}`},
+		apiResponse{status: http.StatusNoContent},
+	)
+
+	_, err := client.Run(docker.RunArgs{
+		Image:      "my-spam",
+		Args:       []string{"do", "something"},
+		Entrypoint: []string{"/bin/sh", "-c"},
+		WorkDir:    "/var/lib/spam",
+		User:       "1000:1000",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[0].body, jc.DeepEquals, map[string]interface{}{
+		"Image":      "my-spam",
+		"Cmd":        []interface{}{"do", "something"},
+		"Entrypoint": []interface{}{"/bin/sh", "-c"},
+		"WorkingDir": "/var/lib/spam",
+		"User":       "1000:1000",
+		"HostConfig": map[string]interface{}{},
+	})
+}
+
+func (s *apiSuite) TestRunResources(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusCreated, body: `{"Id":"eggs"}`},
+		apiResponse{status: http.StatusNoContent},
+	)
+
+	_, err := client.Run(docker.RunArgs{
+		Image: "my-spam",
+		Resources: docker.Resources{
+			Memory:         512 * docker.MiB,
+			MemorySwap:     -1,
+			CPUShares:      512,
+			CPUs:           1.5,
+			PidsLimit:      100,
+			OOMKillDisable: true,
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[0].body, jc.DeepEquals, map[string]interface{}{
+		"Image": "my-spam",
+		"HostConfig": map[string]interface{}{
+			"Memory":         float64(512 << 20),
+			"MemorySwap":     float64(-1),
+			"CpuShares":      float64(512),
+			"NanoCpus":       float64(1.5e9),
+			"PidsLimit":      float64(100),
+			"OomKillDisable": true,
+		},
+	})
+}
+
+func (s *apiSuite) TestRunTypedMounts(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusCreated, body: `{"Id":"eggs"}`},
+		apiResponse{status: http.StatusNoContent},
+	)
+
+	_, err := client.Run(docker.RunArgs{
+		Image: "my-spam",
+		Mounts: []docker.MountAssignment{{
+			Type:        docker.MountBind,
+			External:    "/srv/data",
+			Internal:    "/data",
+			ReadOnly:    true,
+			Propagation: "rslave",
+		}, {
+			Type:     docker.MountVolume,
+			External: "cache",
+			Internal: "/cache",
+			Relabel:  "z",
+		}, {
+			Type:      docker.MountTmpfs,
+			Internal:  "/run",
+			TmpfsSize: 64 * docker.MiB,
+			TmpfsMode: 01770,
+		}},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[0].body, jc.DeepEquals, map[string]interface{}{
+		"Image": "my-spam",
+		"HostConfig": map[string]interface{}{
+			"Binds": []interface{}{"cache:/cache:rw,z"},
+			"Mounts": []interface{}{
+				map[string]interface{}{
+					"Type":     "bind",
+					"Source":   "/srv/data",
+					"Target":   "/data",
+					"ReadOnly": true,
+					"BindOptions": map[string]interface{}{
+						"Propagation": "rslave",
+					},
+				},
+				map[string]interface{}{
+					"Type":   "tmpfs",
+					"Target": "/run",
+					"TmpfsOptions": map[string]interface{}{
+						"SizeBytes": float64(64 << 20),
+						"Mode":      float64(01770),
+					},
+				},
+			},
+		},
+	})
+}
+
+func (s *apiSuite) TestRunRestartPolicy(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusCreated, body: `{"Id":"eggs"}`},
+		apiResponse{status: http.StatusNoContent},
+	)
+
+	_, err := client.Run(docker.RunArgs{
+		Image:         "my-spam",
+		RestartPolicy: docker.RestartPolicy{Name: docker.RestartUnlessStopped},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[0].body, jc.DeepEquals, map[string]interface{}{
+		"Image": "my-spam",
+		"HostConfig": map[string]interface{}{
+			"RestartPolicy": map[string]interface{}{
+				"Name":              "unless-stopped",
+				"MaximumRetryCount": float64(0),
+			},
+		},
+	})
+}
+
+func (s *apiSuite) TestRunLabels(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusCreated, body: `{"Id":"eggs"}`},
+		apiResponse{status: http.StatusNoContent},
+	)
+
+	_, err := client.Run(docker.RunArgs{
+		Image:  "my-spam",
+		Labels: map[string]string{docker.LabelUnit: "spam/0"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[0].body, jc.DeepEquals, map[string]interface{}{
+		"Image":      "my-spam",
+		"Labels":     map[string]interface{}{"com.canonical.juju.unit": "spam/0"},
+		"HostConfig": map[string]interface{}{},
+	})
+}
+
+func (s *apiSuite) TestFindByLabels(c *gc.C) {
+	client, fake := s.newAPIClient(c, apiResponse{status: http.StatusOK, body: `[]`})
+
+	summaries, err := client.FindByLabels(map[string]string{docker.LabelUnit: "spam/0"})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(summaries, gc.HasLen, 0)
+	c.Assert(fake.requests, gc.HasLen, 1)
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/json?"+url.Values{
+		"all":     {"1"},
+		"filters": {`{"label":["com.canonical.juju.unit=spam/0"]}`},
+	}.Encode())
+}
+
+func (s *apiSuite) TestRunBadCommand(c *gc.C) {
+	client, fake := s.newAPIClient(c)
+
+	_, err := client.Run(docker.RunArgs{
+		Image:   "my-spam",
+		Command: `sh -c 'echo hello world`,
+	})
+
+	c.Check(err, gc.ErrorMatches, `invalid run args: Command: invalid command .*: unterminated single quote`)
+	c.Check(fake.requests, gc.HasLen, 0)
+}
+
+func (s *apiSuite) TestRunCreateFailed(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusConflict, body: `{"message":"Conflict. The name \"spam\" is already in use by container b508c7d5c272."}`},
+	)
+
+	_, err := client.Run(docker.RunArgs{Name: "spam", Image: "my-spam"})
+
+	c.Check(err, gc.ErrorMatches, `docker POST /containers/create\?name=spam failed with status 409: Conflict. .*`)
+	c.Check(docker.IsConflict(err), jc.IsTrue)
+	c.Check(fake.requests, gc.HasLen, 1)
+}
+
+func (s *apiSuite) TestRunPullsMissingImage(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusNotFound, body: `{"message":"No such image: my-spam:1.0"}`},
+		apiResponse{status: http.StatusOK, body: `{"status":"Pulling from library/my-spam","id":"1.0"}
+{"status":"Downloading","progressDetail":{"current":1024,"total":2048},"id":"fb434121fc77"}
+{"status":"Status: Downloaded newer image for my-spam:1.0"}
+`},
+		apiResponse{status: http.StatusCreated, body: `{"Id":"eggs"}`},
+		apiResponse{status: http.StatusNoContent},
+	)
+
+	id, err := client.Run(docker.RunArgs{Image: "my-spam:1.0"})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(id, gc.Equals, "eggs")
+	c.Assert(fake.requests, gc.HasLen, 4)
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/create")
+	c.Check(fake.requests[1].method, gc.Equals, "POST")
+	c.Check(fake.requests[1].uri, gc.Equals, "/images/create?fromImage=my-spam&tag=1.0")
+	c.Check(fake.requests[2].uri, gc.Equals, "/containers/create")
+	c.Check(fake.requests[3].uri, gc.Equals, "/containers/eggs/start")
+}
+
+func (s *apiSuite) TestRunPullFailed(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusNotFound, body: `{"message":"No such image: my-spam:latest"}`},
+		apiResponse{status: http.StatusOK, body: `{"status":"Pulling repository my-spam"}
+{"errorDetail":{"message":"manifest for my-spam:latest not found: manifest unknown"},"error":"manifest for my-spam:latest not found: manifest unknown"}
+`},
+	)
+
+	_, err := client.Run(docker.RunArgs{Image: "my-spam"})
+
+	c.Check(err, gc.ErrorMatches, `docker POST /images/create\?fromImage=my-spam&tag=latest failed: manifest for my-spam:latest not found: manifest unknown`)
+	c.Check(docker.IsImageNotFound(err), jc.IsTrue)
+	c.Check(fake.requests, gc.HasLen, 2)
+}
+
+func (s *apiSuite) TestRunPullRefused(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusNotFound, body: `{"message":"No such image: localhost:5000/my-spam@sha256:aaaa"}`},
+		apiResponse{status: http.StatusNotFound, body: `{"message":"pull access denied for localhost:5000/my-spam, repository does not exist or may require 'docker login'"}`},
+	)
+
+	_, err := client.Run(docker.RunArgs{Image: "localhost:5000/my-spam@sha256:aaaa"})
+
+	c.Check(docker.IsImageNotFound(err), jc.IsTrue)
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[1].uri, gc.Equals, "/images/create?fromImage=localhost%3A5000%2Fmy-spam%40sha256%3Aaaaa")
+}
+
+func (s *apiSuite) TestInspectOkay(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusOK, body: fakeInspectObject},
+	)
+
+	info, err := client.Inspect("sad_perlman")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(info, jc.DeepEquals, (*docker.Info)(fakeInfo))
+	c.Assert(fake.requests, gc.HasLen, 1)
+	c.Check(fake.requests[0].method, gc.Equals, "GET")
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/sad_perlman/json")
+}
+
+func (s *apiSuite) TestInspectPlainTextError(c *gc.C) {
+	client, _ := s.newAPIClient(c,
+		apiResponse{status: http.StatusNotFound, body: "no such id: sad_perlman\n"},
+	)
+
+	_, err := client.Inspect("sad_perlman")
+
+	c.Check(err, gc.ErrorMatches, `docker GET /containers/sad_perlman/json failed with status 404: no such id: sad_perlman`)
+}
+
+func (s *apiSuite) TestInspectMany(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusOK, body: fakeInspectObject},
+		apiResponse{status: http.StatusNotFound, body: `{"message":"No such container: spam"}`},
+	)
+
+	results, err := client.InspectMany("sad_perlman", "spam")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(results, gc.HasLen, 2)
+	c.Check(results[0].ID, gc.Equals, "sad_perlman")
+	c.Check(results[0].Info, jc.DeepEquals, (*docker.Info)(fakeInfo))
+	c.Check(results[0].Err, jc.ErrorIsNil)
+	c.Check(results[1].ID, gc.Equals, "spam")
+	c.Check(results[1].Info, gc.IsNil)
+	c.Check(docker.IsNotFound(results[1].Err), jc.IsTrue)
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/sad_perlman/json")
+	c.Check(fake.requests[1].uri, gc.Equals, "/containers/spam/json")
+}
+
+func (s *apiSuite) TestInspectManyFailed(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusInternalServerError, body: `{"message":"something unexpected happened"}`},
+	)
+
+	_, err := client.InspectMany("sad_perlman", "spam")
+
+	c.Check(err, gc.ErrorMatches, "docker GET /containers/sad_perlman/json failed with status 500: .*")
+	c.Check(fake.requests, gc.HasLen, 1)
+}
+
+func (s *apiSuite) TestStopOkay(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusNoContent},
+	)
+
+	err := client.Stop("sad_perlman")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(fake.requests, gc.HasLen, 1)
+	c.Check(fake.requests[0].method, gc.Equals, "POST")
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/sad_perlman/stop")
+}
+
+func (s *apiSuite) TestStopAlreadyStopped(c *gc.C) {
+	client, _ := s.newAPIClient(c,
+		apiResponse{status: http.StatusNotModified},
+	)
+
+	err := client.Stop("sad_perlman")
+	c.Check(err, jc.ErrorIsNil)
+}
+
+func (s *apiSuite) TestStopWithArgs(c *gc.C) {
+	exited := inspectObject(exitedInspectOutput(137))
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusNoContent},
+		apiResponse{status: http.StatusOK, body: exited},
+	)
+
+	result, err := client.StopWithArgs("sad_perlman", docker.StopArgs{
+		Timeout: time.Minute,
+		Signal:  "SIGQUIT",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(result, jc.DeepEquals, &docker.StopResult{
+		ExitCode: 137,
+		Killed:   true,
+	})
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[0].method, gc.Equals, "POST")
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/sad_perlman/stop?signal=SIGQUIT&t=60")
+	c.Check(fake.requests[1].uri, gc.Equals, "/containers/sad_perlman/json")
+}
+
+func (s *apiSuite) TestRemoveOkay(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusNoContent},
+	)
+
+	err := client.Remove("sad_perlman")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(fake.requests, gc.HasLen, 1)
+	c.Check(fake.requests[0].method, gc.Equals, "DELETE")
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/sad_perlman")
+}
+
+func (s *apiSuite) TestRemoveWithArgs(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusNoContent},
+	)
+
+	err := client.RemoveWithArgs("sad_perlman", docker.RemoveArgs{
+		Force:   true,
+		Volumes: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(fake.requests, gc.HasLen, 1)
+	c.Check(fake.requests[0].method, gc.Equals, "DELETE")
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/sad_perlman?force=1&v=1")
+}
+
+func (s *apiSuite) TestRemoveWithArgsIgnoreNotFound(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusNotFound, body: `{"message":"No such container: sad_perlman"}`},
+	)
+
+	err := client.RemoveWithArgs("sad_perlman", docker.RemoveArgs{
+		IgnoreNotFound: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/sad_perlman")
+}
+
+func (s *apiSuite) TestLogsMultiplexed(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusOK, body: fakeInspectObject},
+		apiResponse{status: http.StatusOK, body: multiplexed(
+			1, "hello\n",
+			2, "oops\n",
+			1, "world\n",
+		)},
+	)
+
+	since := time.Date(2015, 6, 25, 11, 5, 53, 500, time.UTC)
+	r, err := client.Logs("sad_perlman", docker.LogsArgs{
+		Tail:       10,
+		Since:      since,
+		Timestamps: true,
+		Follow:     true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	data, err := ioutil.ReadAll(r)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(r.Close(), jc.ErrorIsNil)
+
+	c.Check(string(data), gc.Equals, "hello\noops\nworld\n")
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/sad_perlman/json")
+	c.Check(fake.requests[1].method, gc.Equals, "GET")
+	c.Check(fake.requests[1].uri, gc.Equals, "/containers/sad_perlman/logs?follow=1&since=1435230353.000000500&stderr=1&stdout=1&tail=10&timestamps=1")
+}
+
+func (s *apiSuite) TestLogsTTY(c *gc.C) {
+	tty := strings.Replace(fakeInspectObject, `"Tty": false`, `"Tty": true`, 1)
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusOK, body: tty},
+		apiResponse{status: http.StatusOK, body: "hello\r\nworld\r\n"},
+	)
+
+	r, err := client.Logs("sad_perlman", docker.LogsArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(string(data), gc.Equals, "hello\r\nworld\r\n")
+	c.Check(fake.requests[1].uri, gc.Equals, "/containers/sad_perlman/logs?stderr=1&stdout=1")
+}
+
+func (s *apiSuite) TestLogsTruncated(c *gc.C) {
+	client, _ := s.newAPIClient(c,
+		apiResponse{status: http.StatusOK, body: fakeInspectObject},
+		apiResponse{status: http.StatusOK, body: multiplexed(1, "hello\n")[:10]},
+	)
+
+	r, err := client.Logs("sad_perlman", docker.LogsArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+
+	c.Check(err, gc.Equals, io.ErrUnexpectedEOF)
+	c.Check(string(data), gc.Equals, "he")
+}
+
+func (s *apiSuite) TestLogsNotFound(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusNotFound, body: `{"message":"No such container: sad_perlman"}`},
+	)
+
+	_, err := client.Logs("sad_perlman", docker.LogsArgs{})
+
+	c.Check(docker.IsNotFound(err), jc.IsTrue)
+	c.Check(fake.requests, gc.HasLen, 1)
+}
+
+func (s *apiSuite) TestExecOkay(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusCreated, body: `{"Id":"exec-id"}`},
+		apiResponse{hijack: true, readStdin: true, body: multiplexed(
+			1, "PONG\n",
+			2, "warning\n",
+		)},
+		apiResponse{status: http.StatusOK, body: `{"ID":"exec-id","Running":false,"ExitCode":0}`},
+	)
+
+	result, err := client.Exec("sad_perlman", docker.ExecArgs{
+		Cmd: []string{"redis-cli", "ping"},
+		Env: map[string]string{
+			"FOO": "bar",
+		},
+		User:    "1000",
+		WorkDir: "/data",
+		Stdin:   strings.NewReader("input"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(result, jc.DeepEquals, &docker.ExecResult{
+		Stdout:   []byte("PONG\n"),
+		Stderr:   []byte("warning\n"),
+		ExitCode: 0,
+	})
+	c.Assert(fake.requests, gc.HasLen, 3)
+	c.Check(fake.requests[0].method, gc.Equals, "POST")
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/sad_perlman/exec")
+	c.Check(fake.requests[0].body, jc.DeepEquals, map[string]interface{}{
+		"AttachStdin":  true,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Tty":          false,
+		"Cmd":          []interface{}{"redis-cli", "ping"},
+		"Env":          []interface{}{"FOO=bar"},
+		"User":         "1000",
+		"WorkingDir":   "/data",
+	})
+	c.Check(fake.requests[1].method, gc.Equals, "POST")
+	c.Check(fake.requests[1].uri, gc.Equals, "/exec/exec-id/start")
+	c.Check(fake.requests[1].body, jc.DeepEquals, map[string]interface{}{
+		"Detach": false,
+		"Tty":    false,
+	})
+	c.Check(fake.requests[1].stdin, gc.Equals, "input")
+	c.Check(fake.requests[2].method, gc.Equals, "GET")
+	c.Check(fake.requests[2].uri, gc.Equals, "/exec/exec-id/json")
+}
+
+func (s *apiSuite) TestExecTTYExitCode(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusCreated, body: `{"Id":"exec-id"}`},
+		apiResponse{hijack: true, body: "unhealthy\r\n"},
+		apiResponse{status: http.StatusOK, body: `{"ID":"exec-id","Running":false,"ExitCode":3}`},
+	)
+
+	result, err := client.Exec("sad_perlman", docker.ExecArgs{
+		Cmd: []string{"check-health"},
+		TTY: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(result, jc.DeepEquals, &docker.ExecResult{
+		Stdout:   []byte("unhealthy\r\n"),
+		ExitCode: 3,
+	})
+	c.Check(fake.requests[0].body, jc.DeepEquals, map[string]interface{}{
+		"AttachStdin":  false,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Tty":          true,
+		"Cmd":          []interface{}{"check-health"},
+	})
+}
+
+func (s *apiSuite) TestExecNotRunning(c *gc.C) {
+	client, _ := s.newAPIClient(c,
+		apiResponse{status: http.StatusConflict, body: `{"message":"Container sad_perlman is not running"}`},
+	)
+
+	_, err := client.Exec("sad_perlman", docker.ExecArgs{
+		Cmd: []string{"check-health"},
+	})
+
+	c.Check(docker.IsConflict(err), jc.IsTrue)
+}
+
+func (s *apiSuite) TestWaitOkay(c *gc.C) {
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusOK, body: `{"StatusCode":137}`},
+		apiResponse{status: http.StatusOK, body: inspectObject(exitedInspectOutput(137))},
+	)
+
+	status, err := client.Wait(context.Background(), "sad_perlman")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(status, jc.DeepEquals, &docker.ExitStatus{
+		ExitCode: 137,
+	})
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[0].method, gc.Equals, "POST")
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/sad_perlman/wait")
+	c.Check(fake.requests[1].uri, gc.Equals, "/containers/sad_perlman/json")
+}
+
+func (s *apiSuite) TestWaitUntilHealthy(c *gc.C) {
+	s.PatchValue(docker.PollInterval, time.Millisecond)
+	client, fake := s.newAPIClient(c,
+		apiResponse{status: http.StatusOK, body: `{"State":{"Running":true,"Health":{"Status":"starting"}}}`},
+		apiResponse{status: http.StatusOK, body: `{"State":{"Running":true,"Health":{"Status":"healthy"}}}`},
+	)
+
+	err := client.WaitUntil(context.Background(), "sad_perlman", docker.ConditionHealthy)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(fake.requests, gc.HasLen, 2)
+	c.Check(fake.requests[1].method, gc.Equals, "GET")
+	c.Check(fake.requests[1].uri, gc.Equals, "/containers/sad_perlman/json")
+}
+
+func (s *apiSuite) TestListOkay(c *gc.C) {
+	client, fake := s.newAPIClient(c, apiResponse{status: http.StatusOK, body: `[{
+		"Id": "b508c7d5c272",
+		"Names": ["/sad_perlman", "/eggs/sad_perlman"],
+		"Image": "docker/whalesay",
+		"Labels": {"com.canonical.juju.unit": "spam/0"},
+		"Ports": [
+			{"IP": "0.0.0.0", "PrivatePort": 80, "PublicPort": 8080, "Type": "tcp"},
+			{"IP": "::", "PrivatePort": 80, "PublicPort": 8080, "Type": "tcp"},
+			{"PrivatePort": 443, "Type": "tcp"}
+		],
+		"Status": "Up 2 hours (Paused)"
+	}]`})
+
+	summaries, err := client.List(docker.ListArgs{
+		All:    true,
+		Labels: map[string]string{"com.canonical.juju.unit": "spam/0"},
+		Names:  []string{"sad_perlman"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(summaries, jc.DeepEquals, []docker.ContainerSummary{{
+		ID:     "b508c7d5c272",
+		Names:  []string{"sad_perlman", "eggs/sad_perlman"},
+		Image:  "docker/whalesay",
+		State:  "paused",
+		Status: "Up 2 hours (Paused)",
+		Labels: map[string]string{"com.canonical.juju.unit": "spam/0"},
+		Ports: []docker.PortAssignment{
+			{HostIP: "0.0.0.0", External: 8080, Internal: 80, Protocol: "tcp"},
+		},
+	}})
+	c.Assert(fake.requests, gc.HasLen, 1)
+	c.Check(fake.requests[0].method, gc.Equals, "GET")
+	c.Check(fake.requests[0].uri, gc.Equals, "/containers/json?"+url.Values{
+		"all":     {"1"},
+		"filters": {`{"label":["com.canonical.juju.unit=spam/0"],"name":["sad_perlman"]}`},
+	}.Encode())
+}
+
+var apiOperationTests = []struct {
+	about string
+	call  func(docker.Client) error
+	uri   string
+}{{
+	about: "start",
+	call:  func(client docker.Client) error { return client.Start("sad_perlman") },
+	uri:   "/containers/sad_perlman/start",
+}, {
+	about: "restart",
+	call:  func(client docker.Client) error { return client.Restart("sad_perlman", 1500*time.Millisecond) },
+	uri:   "/containers/sad_perlman/restart?t=2",
+}, {
+	about: "kill",
+	call:  func(client docker.Client) error { return client.Kill("sad_perlman", "SIGINT") },
+	uri:   "/containers/sad_perlman/kill?signal=SIGINT",
+}, {
+	about: "kill with default signal",
+	call:  func(client docker.Client) error { return client.Kill("sad_perlman", "") },
+	uri:   "/containers/sad_perlman/kill",
+}, {
+	about: "pause",
+	call:  func(client docker.Client) error { return client.Pause("sad_perlman") },
+	uri:   "/containers/sad_perlman/pause",
+}, {
+	about: "unpause",
+	call:  func(client docker.Client) error { return client.Unpause("sad_perlman") },
+	uri:   "/containers/sad_perlman/unpause",
+}}
+
+func (s *apiSuite) TestOperations(c *gc.C) {
+	for i, test := range apiOperationTests {
+		c.Logf("test %d: %s", i, test.about)
+		client, fake := s.newAPIClient(c, apiResponse{status: http.StatusNoContent})
+
+		err := test.call(client)
+		c.Assert(err, jc.ErrorIsNil)
+
+		c.Assert(fake.requests, gc.HasLen, 1)
+		c.Check(fake.requests[0].method, gc.Equals, "POST")
+		c.Check(fake.requests[0].uri, gc.Equals, test.uri)
+	}
+}
+
+func (s *apiSuite) TestRunContextCancelled(c *gc.C) {
+	client, fake := s.newAPIClient(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.RunContext(ctx, docker.RunArgs{Image: "my-spam"})
+
+	c.Check(err, gc.ErrorMatches, "docker POST /containers/create cancelled: context canceled")
+	c.Check(errors.Is(err, context.Canceled), jc.IsTrue)
+	c.Check(fake.requests, gc.HasLen, 0)
+}
+
+func (s *apiSuite) TestInspectContextTimeout(c *gc.C) {
+	client, fake := s.newAPIClient(c)
+	fake.hang = make(chan struct{})
+	defer close(fake.hang)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := client.InspectContext(ctx, "sad_perlman")
+
+	c.Check(err, gc.ErrorMatches, "docker GET /containers/sad_perlman/json timed out: context deadline exceeded")
+	c.Check(errors.Is(err, context.DeadlineExceeded), jc.IsTrue)
+}
+
+func (s *apiSuite) TestStopContextCancelled(c *gc.C) {
+	client, _ := s.newAPIClient(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := client.StopContext(ctx, "sad_perlman")
+
+	c.Check(err, gc.ErrorMatches, "docker POST /containers/sad_perlman/stop cancelled: context canceled")
+}
+
+func (s *apiSuite) TestRemoveContextCancelled(c *gc.C) {
+	client, _ := s.newAPIClient(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := client.RemoveContext(ctx, "sad_perlman")
+
+	c.Check(err, gc.ErrorMatches, "docker DELETE /containers/sad_perlman cancelled: context canceled")
+}
+
+// fakeInspectObject is the API equivalent of fakeInspectOutput.
+var fakeInspectObject = inspectObject(fakeInspectOutput)
+
+// inspectObject converts the docker inspect output for a single
+// container into the API equivalent, which is an object rather than
+// a list.
+func inspectObject(output string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(output), "["), "]")
+}
+
+// multiplexed returns the multiplexed stream made up of the given
+// pairs of stream type and payload.
+func multiplexed(frames ...interface{}) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(frames); i += 2 {
+		payload := frames[i+1].(string)
+		header := make([]byte, 8)
+		header[0] = byte(frames[i].(int))
+		binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+		buf.Write(header)
+		buf.WriteString(payload)
+	}
+	return buf.String()
+}
+
+type apiResponse struct {
+	status int
+	body   string
+
+	// hijack indicates that the daemon should take over the connection
+	// and send the body as a raw stream.
+	hijack bool
+	// readStdin indicates that the daemon should read all the input
+	// from a hijacked connection before sending the body.
+	readStdin bool
+}
+
+type apiRequest struct {
+	method string
+	uri    string
+	body   interface{}
+	stdin  string
+}
+
+// fakeDaemon is an http.Handler that records the requests it gets
+// and replies with the canned responses.
+type fakeDaemon struct {
+	responses []apiResponse
+	requests  []apiRequest
+
+	// hang, if set, makes the daemon wait for it to be closed before
+	// replying.
+	hang chan struct{}
+}
+
+func (fd *fakeDaemon) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	recorded := apiRequest{
+		method: req.Method,
+		uri:    req.URL.RequestURI(),
+	}
+	if data, _ := ioutil.ReadAll(req.Body); len(data) > 0 {
+		if err := json.Unmarshal(data, &recorded.body); err != nil {
+			recorded.body = string(data)
+		}
+	}
+	index := len(fd.requests)
+	fd.requests = append(fd.requests, recorded)
+
+	if fd.hang != nil {
+		<-fd.hang
+	}
+
+	if index >= len(fd.responses) {
+		http.Error(w, "unexpected request", http.StatusInternalServerError)
+		return
+	}
+	resp := fd.responses[index]
+	if resp.hijack {
+		fd.hijack(w, index, resp)
+		return
+	}
+	w.WriteHeader(resp.status)
+	w.Write([]byte(resp.body))
+}
+
+// hijack takes over the connection, as the daemon does when attaching
+// to a process.
+func (fd *fakeDaemon) hijack(w http.ResponseWriter, index int, resp apiResponse) {
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+
+	rw.WriteString("HTTP/1.1 101 UPGRADED\r\n")
+	rw.WriteString("Content-Type: application/vnd.docker.raw-stream\r\n")
+	rw.WriteString("Connection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	rw.Flush()
+	if resp.readStdin {
+		stdin, _ := ioutil.ReadAll(rw)
+		fd.requests[index].stdin = string(stdin)
+	}
+	rw.WriteString(resp.body)
+	rw.Flush()
+}
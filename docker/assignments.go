@@ -0,0 +1,464 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// These are the limits on port numbers.
+const (
+	minPort = 1
+	maxPort = 65535
+)
+
+// protocols holds the network protocols docker can publish ports for.
+var protocols = map[string]bool{
+	"tcp":  true,
+	"udp":  true,
+	"sctp": true,
+}
+
+// ParsePortAssignment converts a port mapping in docker syntax into a
+// PortAssignment. The syntax is [[host-ip:]external:]internal[/protocol],
+// where either port may be a range (e.g. "7000-7010") and the external
+// port may be left out to have docker pick one (e.g. "127.0.0.1::80").
+// An IPv6 host address must be in square brackets.
+func ParsePortAssignment(s string) (PortAssignment, error) {
+	var pa PortAssignment
+	ports := s
+	if i := strings.LastIndex(s, "/"); i >= 0 {
+		ports, pa.Protocol = s[:i], s[i+1:]
+		if pa.Protocol == "" {
+			return PortAssignment{}, fmt.Errorf("invalid port assignment %q: empty protocol", s)
+		}
+	}
+
+	if strings.HasPrefix(ports, "[") {
+		end := strings.Index(ports, "]:")
+		if end < 0 {
+			return PortAssignment{}, fmt.Errorf("invalid port assignment %q: bad host IP", s)
+		}
+		pa.HostIP, ports = ports[1:end], ports[end+2:]
+		if !strings.Contains(ports, ":") {
+			return PortAssignment{}, fmt.Errorf("invalid port assignment %q: expected [[host-ip:]external:]internal[/protocol]", s)
+		}
+	}
+
+	var external, internal string
+	parts := strings.Split(ports, ":")
+	switch len(parts) {
+	case 1:
+		internal = parts[0]
+	case 2:
+		external, internal = parts[0], parts[1]
+	case 3:
+		if pa.HostIP != "" {
+			return PortAssignment{}, fmt.Errorf("invalid port assignment %q: expected [[host-ip:]external:]internal[/protocol]", s)
+		}
+		pa.HostIP, external, internal = parts[0], parts[1], parts[2]
+		if pa.HostIP == "" || strings.Contains(pa.HostIP, ":") {
+			return PortAssignment{}, fmt.Errorf("invalid port assignment %q: bad host IP", s)
+		}
+	default:
+		return PortAssignment{}, fmt.Errorf("invalid port assignment %q: expected [[host-ip:]external:]internal[/protocol]", s)
+	}
+	if external == "" && len(parts) == 2 && pa.HostIP == "" {
+		return PortAssignment{}, fmt.Errorf("invalid port assignment %q: empty external port", s)
+	}
+
+	internalCount := 0
+	var err error
+	if pa.Internal, internalCount, err = parsePorts(internal); err != nil {
+		return PortAssignment{}, fmt.Errorf("invalid port assignment %q: %s", s, err)
+	}
+	pa.Count = internalCount
+	if external != "" {
+		var externalCount int
+		if pa.External, externalCount, err = parsePorts(external); err != nil {
+			return PortAssignment{}, fmt.Errorf("invalid port assignment %q: %s", s, err)
+		}
+		if err := validatePort(pa.External); err != nil {
+			return PortAssignment{}, fmt.Errorf("invalid port assignment %q: external %s", s, err)
+		}
+		if externalCount != internalCount {
+			return PortAssignment{}, fmt.Errorf("invalid port assignment %q: external and internal ranges differ in size", s)
+		}
+	}
+
+	if err := pa.Validate(); err != nil {
+		return PortAssignment{}, err
+	}
+	return pa, nil
+}
+
+// parsePorts converts a port number or range (e.g. "7000-7010") into
+// the first port and the number of ports, which is zero for a single
+// port. It checks only the syntax.
+func parsePorts(s string) (int, int, error) {
+	parts := strings.SplitN(s, "-", 2)
+	first, err := parsePort(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(parts) == 1 {
+		return first, 0, nil
+	}
+	last, err := parsePort(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	if last <= first {
+		return 0, 0, fmt.Errorf("bad port range %q", s)
+	}
+	return first, last - first + 1, nil
+}
+
+// parsePort converts a port number, checking only its syntax. Only
+// the canonical form is accepted, so that it round-trips.
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || strconv.Itoa(port) != s {
+		return 0, fmt.Errorf("bad port %q", s)
+	}
+	return port, nil
+}
+
+// Validate checks that the mapping is one docker will accept.
+func (pa PortAssignment) Validate() error {
+	if pa.HostIP != "" && net.ParseIP(pa.HostIP) == nil {
+		return fmt.Errorf("invalid port assignment %q: bad host IP %q", pa, pa.HostIP)
+	}
+	if pa.Count < 0 {
+		return fmt.Errorf("invalid port assignment %q: negative count", pa)
+	}
+	last := pa.Count - 1
+	if last < 0 {
+		last = 0
+	}
+	if pa.External != 0 {
+		if err := validatePort(pa.External); err != nil {
+			return fmt.Errorf("invalid port assignment %q: external %s", pa, err)
+		}
+		if err := validatePort(pa.External + last); err != nil {
+			return fmt.Errorf("invalid port assignment %q: external %s", pa, err)
+		}
+	}
+	if err := validatePort(pa.Internal); err != nil {
+		return fmt.Errorf("invalid port assignment %q: internal %s", pa, err)
+	}
+	if err := validatePort(pa.Internal + last); err != nil {
+		return fmt.Errorf("invalid port assignment %q: internal %s", pa, err)
+	}
+	if pa.Protocol != "" && !protocols[pa.Protocol] {
+		return fmt.Errorf("invalid port assignment %q: unknown protocol %q", pa, pa.Protocol)
+	}
+	return nil
+}
+
+// protocol returns the network protocol for the mapping, defaulting to
+// tcp as docker does.
+func (pa PortAssignment) protocol() string {
+	if pa.Protocol == "" {
+		return "tcp"
+	}
+	return pa.Protocol
+}
+
+// validatePort checks that the port number is in range.
+func validatePort(port int) error {
+	if port < minPort || port > maxPort {
+		return fmt.Errorf("port %d out of range %d-%d", port, minPort, maxPort)
+	}
+	return nil
+}
+
+// ResolvePorts returns the actual host bindings for the requested port
+// mappings, once the container has started. This finds the ports that
+// docker picked where External was left out. There is one result per
+// port, so ranges are expanded.
+func ResolvePorts(info *Info, ports []PortAssignment) ([]PortAssignment, error) {
+	if info == nil {
+		return nil, fmt.Errorf("can't resolve ports without container info")
+	}
+	bound := info.Ports()
+	var resolved []PortAssignment
+	for _, pa := range ports {
+		for _, p := range pa.expand() {
+			found := false
+			for _, b := range bound {
+				if b.Internal != p.Internal || b.Protocol != p.protocol() {
+					continue
+				}
+				if p.HostIP != "" && b.HostIP != p.HostIP {
+					continue
+				}
+				if p.External != 0 && b.External != p.External {
+					continue
+				}
+				resolved = append(resolved, b)
+				found = true
+				break
+			}
+			if !found {
+				return nil, fmt.Errorf("port %d/%s is not published", p.Internal, p.protocol())
+			}
+		}
+	}
+	return resolved, nil
+}
+
+// ParseMountAssignment converts a volume mount mapping in docker syntax
+// into a MountAssignment. Either docker's shorthand
+// (e.g. "/srv/data:/data:ro", where the mode is optional) or its long
+// form (e.g. "type=bind,source=/srv/data,target=/data,readonly") may
+// be used.
+func ParseMountAssignment(s string) (MountAssignment, error) {
+	if isLongFormMount(s) {
+		return parseLongFormMount(s)
+	}
+
+	var ma MountAssignment
+	parts := strings.Split(s, ":")
+	switch len(parts) {
+	case 3:
+		ma.Mode = parts[2]
+		if ma.Mode == "" {
+			return MountAssignment{}, fmt.Errorf("invalid mount assignment %q: empty mode", s)
+		}
+		fallthrough
+	case 2:
+		ma.External, ma.Internal = parts[0], parts[1]
+	default:
+		return MountAssignment{}, fmt.Errorf("invalid mount assignment %q: expected external:internal[:mode]", s)
+	}
+	if err := ma.Validate(); err != nil {
+		return MountAssignment{}, err
+	}
+	return ma, nil
+}
+
+// longFormMountKeys holds the keys of docker's long form for mounts
+// that are understood, mapped to the canonical key.
+var longFormMountKeys = map[string]string{
+	"type":             "type",
+	"source":           "source",
+	"src":              "source",
+	"target":           "target",
+	"destination":      "target",
+	"dst":              "target",
+	"readonly":         "readonly",
+	"ro":               "readonly",
+	"bind-propagation": "bind-propagation",
+	"volume-nocopy":    "volume-nocopy",
+	"tmpfs-size":       "tmpfs-size",
+	"tmpfs-mode":       "tmpfs-mode",
+}
+
+// isLongFormMount reports whether the mount is in docker's long form,
+// rather than its shorthand.
+func isLongFormMount(s string) bool {
+	field := strings.SplitN(s, ",", 2)[0]
+	parts := strings.SplitN(field, "=", 2)
+	return len(parts) == 2 && longFormMountKeys[parts[0]] != ""
+}
+
+// parseLongFormMount converts a mount in docker's long form into
+// a MountAssignment.
+func parseLongFormMount(s string) (MountAssignment, error) {
+	ma := MountAssignment{
+		Type: MountVolume,
+	}
+	for _, field := range strings.Split(s, ",") {
+		parts := strings.SplitN(field, "=", 2)
+		key := longFormMountKeys[parts[0]]
+		value := ""
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+		var err error
+		switch key {
+		case "type":
+			ma.Type = MountType(value)
+		case "source":
+			ma.External = value
+		case "target":
+			ma.Internal = value
+		case "readonly":
+			ma.ReadOnly, err = parseMountFlag(parts)
+		case "bind-propagation":
+			ma.Propagation = value
+		case "volume-nocopy":
+			ma.NoCopy, err = parseMountFlag(parts)
+		case "tmpfs-size":
+			ma.TmpfsSize, err = ParseByteSize(value)
+		case "tmpfs-mode":
+			ma.TmpfsMode, err = parseFileMode(value)
+		default:
+			err = fmt.Errorf("unknown option %q", parts[0])
+		}
+		if err != nil {
+			return MountAssignment{}, fmt.Errorf("invalid mount assignment %q: %s", s, err)
+		}
+	}
+	if err := ma.Validate(); err != nil {
+		return MountAssignment{}, err
+	}
+	return ma, nil
+}
+
+// parseMountFlag converts a boolean option of docker's long form for
+// mounts, which may be given without a value to mean true.
+func parseMountFlag(parts []string) (bool, error) {
+	if len(parts) == 1 {
+		return true, nil
+	}
+	value, err := strconv.ParseBool(parts[1])
+	if err != nil {
+		return false, fmt.Errorf("bad value for %s: %q", parts[0], parts[1])
+	}
+	return value, nil
+}
+
+// parseFileMode converts a file mode in octal (e.g. "1777") into unix
+// mode bits.
+func parseFileMode(s string) (uint32, error) {
+	bits, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || bits&^01777 != 0 {
+		return 0, fmt.Errorf("bad file mode %q", s)
+	}
+	return uint32(bits), nil
+}
+
+// volumeName matches the names docker allows for volumes.
+var volumeName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// propagations holds the bind propagation modes docker accepts.
+var propagations = map[string]bool{
+	"shared":   true,
+	"rshared":  true,
+	"slave":    true,
+	"rslave":   true,
+	"private":  true,
+	"rprivate": true,
+}
+
+// Validate checks that the mapping is one docker will accept.
+func (ma MountAssignment) Validate() error {
+	if !path.IsAbs(ma.Internal) {
+		return fmt.Errorf("invalid mount assignment %q: internal path %q is not absolute", ma, ma.Internal)
+	}
+	if ma.Internal == "/" {
+		return fmt.Errorf("invalid mount assignment %q: can't mount over the container's root", ma)
+	}
+	if ma.Type == "" {
+		if !path.IsAbs(ma.External) && !volumeName.MatchString(ma.External) {
+			return fmt.Errorf("invalid mount assignment %q: external %q is neither an absolute path nor a volume name", ma, ma.External)
+		}
+		if ma.ReadOnly || ma.Propagation != "" || ma.Relabel != "" || ma.NoCopy || ma.TmpfsSize != 0 || ma.TmpfsMode != 0 {
+			return fmt.Errorf("invalid mount assignment %q: options other than the mode need a type", ma)
+		}
+		if ma.Mode != "" {
+			if err := validateMountMode(ma.Mode); err != nil {
+				return fmt.Errorf("invalid mount assignment %q: %s", ma, err)
+			}
+		}
+		return nil
+	}
+
+	if ma.Mode != "" {
+		return fmt.Errorf("invalid mount assignment %q: mode can't be used with a type", ma)
+	}
+	if err := ma.validateTyped(); err != nil {
+		return fmt.Errorf("invalid mount assignment %q: %s", ma, err)
+	}
+	return nil
+}
+
+// validateTyped checks the options of a mount with a type.
+func (ma MountAssignment) validateTyped() error {
+	if ma.Propagation != "" && !propagations[ma.Propagation] {
+		return fmt.Errorf("unknown bind propagation %q", ma.Propagation)
+	}
+	if ma.Relabel != "" && ma.Relabel != "z" && ma.Relabel != "Z" {
+		return fmt.Errorf("unknown relabel %q", ma.Relabel)
+	}
+	switch ma.Type {
+	case MountBind:
+		if !path.IsAbs(ma.External) {
+			return fmt.Errorf("external path %q is not absolute", ma.External)
+		}
+		if ma.NoCopy {
+			return fmt.Errorf("nocopy is only allowed for volumes")
+		}
+	case MountVolume:
+		if ma.External != "" && !volumeName.MatchString(ma.External) {
+			return fmt.Errorf("bad volume name %q", ma.External)
+		}
+		if ma.External == "" && ma.Relabel != "" {
+			return fmt.Errorf("relabel needs a named volume")
+		}
+		if ma.Propagation != "" {
+			return fmt.Errorf("bind propagation is only allowed for binds")
+		}
+	case MountTmpfs:
+		if ma.External != "" {
+			return fmt.Errorf("tmpfs mounts have no source")
+		}
+		if ma.Propagation != "" || ma.Relabel != "" || ma.NoCopy {
+			return fmt.Errorf("only the size, mode and readonly options are allowed for tmpfs")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", ma.Type)
+	}
+	if ma.Type != MountTmpfs && (ma.TmpfsSize != 0 || ma.TmpfsMode != 0) {
+		return fmt.Errorf("tmpfs options are only allowed for tmpfs")
+	}
+	if ma.TmpfsSize < 0 {
+		return fmt.Errorf("tmpfs size must not be negative")
+	}
+	if ma.TmpfsMode&^01777 != 0 {
+		return fmt.Errorf("bad tmpfs mode %#o", ma.TmpfsMode)
+	}
+	return nil
+}
+
+// These are the groups of mount options docker accepts. At most one
+// option from each group may be used.
+var mountModeGroups = []map[string]bool{
+	{"rw": true, "ro": true},
+	{"z": true, "Z": true},
+	{"shared": true, "rshared": true, "slave": true, "rslave": true, "private": true, "rprivate": true},
+	{"nocopy": true},
+	// These only affect Docker Desktop for Mac, but are accepted
+	// everywhere.
+	{"consistent": true, "cached": true, "delegated": true},
+}
+
+// validateMountMode checks that the comma-separated mount options
+// (e.g. "ro,z") are ones docker accepts.
+func validateMountMode(mode string) error {
+	used := make([]bool, len(mountModeGroups))
+	for _, option := range strings.Split(mode, ",") {
+		known := false
+		for i, group := range mountModeGroups {
+			if !group[option] {
+				continue
+			}
+			if used[i] {
+				return fmt.Errorf("conflicting mode %q", mode)
+			}
+			used[i], known = true, true
+		}
+		if !known {
+			return fmt.Errorf("unknown mode %q", option)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker_test
+
+import (
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/daemon/network"
+	"github.com/docker/docker/pkg/nat"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju-process-docker/docker"
+)
+
+var _ = gc.Suite(&assignmentsSuite{})
+
+type assignmentsSuite struct{}
+
+var parsePortAssignmentTests = []struct {
+	s   string
+	pa  docker.PortAssignment
+	err string
+}{{
+	s:  "8080:80/tcp",
+	pa: docker.PortAssignment{External: 8080, Internal: 80, Protocol: "tcp"},
+}, {
+	s:  "53:53/udp",
+	pa: docker.PortAssignment{External: 53, Internal: 53, Protocol: "udp"},
+}, {
+	s:  "9000:9000/sctp",
+	pa: docker.PortAssignment{External: 9000, Internal: 9000, Protocol: "sctp"},
+}, {
+	s:  "8080:80",
+	pa: docker.PortAssignment{External: 8080, Internal: 80},
+}, {
+	s:  "65535:1",
+	pa: docker.PortAssignment{External: 65535, Internal: 1},
+}, {
+	s:  "80",
+	pa: docker.PortAssignment{Internal: 80},
+}, {
+	s:  "127.0.0.1:8080:80",
+	pa: docker.PortAssignment{HostIP: "127.0.0.1", External: 8080, Internal: 80},
+}, {
+	s:  "127.0.0.1::80/udp",
+	pa: docker.PortAssignment{HostIP: "127.0.0.1", Internal: 80, Protocol: "udp"},
+}, {
+	s:  "[::1]:8080:80/tcp",
+	pa: docker.PortAssignment{HostIP: "::1", External: 8080, Internal: 80, Protocol: "tcp"},
+}, {
+	s:  "[::1]::80",
+	pa: docker.PortAssignment{HostIP: "::1", Internal: 80},
+}, {
+	s:  "7000-7010:7000-7010/udp",
+	pa: docker.PortAssignment{External: 7000, Internal: 7000, Count: 11, Protocol: "udp"},
+}, {
+	s:  "0.0.0.0:8000-8001:9000-9001",
+	pa: docker.PortAssignment{HostIP: "0.0.0.0", External: 8000, Internal: 9000, Count: 2},
+}, {
+	s:  "7000-7010",
+	pa: docker.PortAssignment{Internal: 7000, Count: 11},
+}, {
+	s:   "1:2:3",
+	err: `invalid port assignment "1:2:3": bad host IP "1"`,
+}, {
+	s:   "1:2:3:4",
+	err: `invalid port assignment "1:2:3:4": expected \[\[host-ip:\]external:\]internal\[/protocol\]`,
+}, {
+	s:   "::1:8080:80",
+	err: `invalid port assignment "::1:8080:80": expected .*`,
+}, {
+	s:   "[::1:8080:80",
+	err: `invalid port assignment "\[::1:8080:80": bad host IP`,
+}, {
+	s:   "[::1]:80",
+	err: `invalid port assignment "\[::1\]:80": expected .*`,
+}, {
+	s:   ":80",
+	err: `invalid port assignment ":80": empty external port`,
+}, {
+	s:   "7000-7010:7000-7005",
+	err: `invalid port assignment "7000-7010:7000-7005": external and internal ranges differ in size`,
+}, {
+	s:   "7010-7000:7010-7000",
+	err: `invalid port assignment "7010-7000:7010-7000": bad port range "7010-7000"`,
+}, {
+	s:   "65535-65536",
+	err: `invalid port assignment "65535-65536": internal port 65536 out of range 1-65535`,
+}, {
+	s:   "http:80",
+	err: `invalid port assignment "http:80": bad port "http"`,
+}, {
+	s:   "08080:80",
+	err: `invalid port assignment "08080:80": bad port "08080"`,
+}, {
+	s:   "0:80",
+	err: `invalid port assignment "0:80": external port 0 out of range 1-65535`,
+}, {
+	s:   "8080:65536/tcp",
+	err: `invalid port assignment "8080:65536/tcp": internal port 65536 out of range 1-65535`,
+}, {
+	s:   "8080:80/icmp",
+	err: `invalid port assignment "8080:80/icmp": unknown protocol "icmp"`,
+}, {
+	s:   "8080:80/",
+	err: `invalid port assignment "8080:80/": empty protocol`,
+}}
+
+func (assignmentsSuite) TestParsePortAssignment(c *gc.C) {
+	for i, test := range parsePortAssignmentTests {
+		c.Logf("test %d: %q", i, test.s)
+
+		pa, err := docker.ParsePortAssignment(test.s)
+
+		if test.err != "" {
+			c.Check(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		if c.Check(err, jc.ErrorIsNil) {
+			c.Check(pa, jc.DeepEquals, test.pa)
+			c.Check(pa.String(), gc.Equals, test.s)
+		}
+	}
+}
+
+var parseMountAssignmentTests = []struct {
+	s   string
+	ma  docker.MountAssignment
+	err string
+}{{
+	s:  "/srv/data:/data:ro",
+	ma: docker.MountAssignment{External: "/srv/data", Internal: "/data", Mode: "ro"},
+}, {
+	s:  "/srv/data:/data",
+	ma: docker.MountAssignment{External: "/srv/data", Internal: "/data"},
+}, {
+	s:  "/srv/data:/data:rw,Z",
+	ma: docker.MountAssignment{External: "/srv/data", Internal: "/data", Mode: "rw,Z"},
+}, {
+	s:  "/srv/data:/data:ro,z,rslave,nocopy",
+	ma: docker.MountAssignment{External: "/srv/data", Internal: "/data", Mode: "ro,z,rslave,nocopy"},
+}, {
+	s:  "/srv/src:/src:cached",
+	ma: docker.MountAssignment{External: "/srv/src", Internal: "/src", Mode: "cached"},
+}, {
+	s:   "/srv/src:/src:cached,delegated",
+	err: `invalid mount assignment "/srv/src:/src:cached,delegated": conflicting mode "cached,delegated"`,
+}, {
+	s:   "/srv/data",
+	err: `invalid mount assignment "/srv/data": expected external:internal\[:mode\]`,
+}, {
+	s:   "/a:/b:ro:rw",
+	err: `invalid mount assignment "/a:/b:ro:rw": expected external:internal\[:mode\]`,
+}, {
+	s:  "data:/data",
+	ma: docker.MountAssignment{External: "data", Internal: "/data"},
+}, {
+	s:  "type=bind,source=/srv/data,target=/data,readonly,bind-propagation=rslave",
+	ma: docker.MountAssignment{Type: docker.MountBind, External: "/srv/data", Internal: "/data", ReadOnly: true, Propagation: "rslave"},
+}, {
+	s:  "type=volume,source=data,target=/data,volume-nocopy",
+	ma: docker.MountAssignment{Type: docker.MountVolume, External: "data", Internal: "/data", NoCopy: true},
+}, {
+	s:  "type=volume,target=/data",
+	ma: docker.MountAssignment{Type: docker.MountVolume, Internal: "/data"},
+}, {
+	s:  "type=tmpfs,target=/run,tmpfs-size=64m,tmpfs-mode=1770",
+	ma: docker.MountAssignment{Type: docker.MountTmpfs, Internal: "/run", TmpfsSize: 64 * docker.MiB, TmpfsMode: 01770},
+}, {
+	s:   "./data:/data",
+	err: `invalid mount assignment "./data:/data": external "./data" is neither an absolute path nor a volume name`,
+}, {
+	s:   "type=bind,src=data,dst=/data",
+	err: `invalid mount assignment "type=bind,source=data,target=/data": external path "data" is not absolute`,
+}, {
+	s:   "type=tmpfs,source=/srv,target=/run",
+	err: `invalid mount assignment .*: tmpfs mounts have no source`,
+}, {
+	s:   "type=volume,target=/data,bind-propagation=shared",
+	err: `invalid mount assignment .*: bind propagation is only allowed for binds`,
+}, {
+	s:   "type=bind,source=/srv,target=/data,bind-propagation=sideways",
+	err: `invalid mount assignment .*: unknown bind propagation "sideways"`,
+}, {
+	s:   "type=nfs,source=/srv,target=/data",
+	err: `invalid mount assignment .*: unknown type "nfs"`,
+}, {
+	s:   "type=bind,source=/srv,target=/data,consistency=cached",
+	err: `invalid mount assignment "type=bind,source=/srv,target=/data,consistency=cached": unknown option "consistency"`,
+}, {
+	s:   "type=bind,source=/srv,target=/data,readonly=maybe",
+	err: `invalid mount assignment .*: bad value for readonly: "maybe"`,
+}, {
+	s:   "type=tmpfs,target=/run,tmpfs-mode=9",
+	err: `invalid mount assignment .*: bad file mode "9"`,
+}, {
+	s:   "/srv/data:data",
+	err: `invalid mount assignment "/srv/data:data": internal path "data" is not absolute`,
+}, {
+	s:   "/srv/data:/",
+	err: `invalid mount assignment "/srv/data:/": can't mount over the container's root`,
+}, {
+	s:   "/srv/data:/data:rx",
+	err: `invalid mount assignment "/srv/data:/data:rx": unknown mode "rx"`,
+}, {
+	s:   "/srv/data:/data:ro,rw",
+	err: `invalid mount assignment "/srv/data:/data:ro,rw": conflicting mode "ro,rw"`,
+}, {
+	s:   "/srv/data:/data:",
+	err: `invalid mount assignment "/srv/data:/data:": empty mode`,
+}}
+
+func (assignmentsSuite) TestParseMountAssignment(c *gc.C) {
+	for i, test := range parseMountAssignmentTests {
+		c.Logf("test %d: %q", i, test.s)
+
+		ma, err := docker.ParseMountAssignment(test.s)
+
+		if test.err != "" {
+			c.Check(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		if c.Check(err, jc.ErrorIsNil) {
+			c.Check(ma, jc.DeepEquals, test.ma)
+			c.Check(ma.String(), gc.Equals, test.s)
+		}
+	}
+}
+
+// boundInfo returns the Info for a container with the ports bound.
+func boundInfo() *docker.Info {
+	return &docker.Info{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			NetworkSettings: &network.Settings{
+				Ports: nat.PortMap{
+					"80/tcp": {
+						{HostIP: "0.0.0.0", HostPort: "32768"},
+						{HostIP: "127.0.0.1", HostPort: "8080"},
+					},
+					"53/udp":   {{HostIP: "0.0.0.0", HostPort: "5353"}},
+					"7000/udp": {{HostIP: "0.0.0.0", HostPort: "7000"}},
+					"7001/udp": {{HostIP: "0.0.0.0", HostPort: "7001"}},
+					"443/tcp":  nil,
+				},
+			},
+		},
+	}
+}
+
+func (assignmentsSuite) TestInfoPorts(c *gc.C) {
+	c.Check(boundInfo().Ports(), jc.DeepEquals, []docker.PortAssignment{
+		{HostIP: "0.0.0.0", External: 5353, Internal: 53, Protocol: "udp"},
+		{HostIP: "0.0.0.0", External: 32768, Internal: 80, Protocol: "tcp"},
+		{HostIP: "127.0.0.1", External: 8080, Internal: 80, Protocol: "tcp"},
+		{HostIP: "0.0.0.0", External: 7000, Internal: 7000, Protocol: "udp"},
+		{HostIP: "0.0.0.0", External: 7001, Internal: 7001, Protocol: "udp"},
+	})
+	c.Check(docker.Info(*fakeInfo).Ports(), gc.HasLen, 0)
+}
+
+func (assignmentsSuite) TestResolvePorts(c *gc.C) {
+	ports, err := docker.ResolvePorts(boundInfo(), []docker.PortAssignment{
+		{Internal: 80},
+		{HostIP: "127.0.0.1", Internal: 80},
+		{External: 5353, Internal: 53, Protocol: "udp"},
+		{Internal: 7000, Count: 2, Protocol: "udp"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(ports, jc.DeepEquals, []docker.PortAssignment{
+		{HostIP: "0.0.0.0", External: 32768, Internal: 80, Protocol: "tcp"},
+		{HostIP: "127.0.0.1", External: 8080, Internal: 80, Protocol: "tcp"},
+		{HostIP: "0.0.0.0", External: 5353, Internal: 53, Protocol: "udp"},
+		{HostIP: "0.0.0.0", External: 7000, Internal: 7000, Protocol: "udp"},
+		{HostIP: "0.0.0.0", External: 7001, Internal: 7001, Protocol: "udp"},
+	})
+}
+
+func (assignmentsSuite) TestResolvePortsNotPublished(c *gc.C) {
+	_, err := docker.ResolvePorts(boundInfo(), []docker.PortAssignment{
+		{Internal: 443},
+	})
+
+	c.Check(err, gc.ErrorMatches, "port 443/tcp is not published")
+}
+
+func (assignmentsSuite) TestResolvePortsNoInfo(c *gc.C) {
+	_, err := docker.ResolvePorts(nil, []docker.PortAssignment{
+		{Internal: 443},
+	})
+	c.Check(err, gc.ErrorMatches, "can't resolve ports without container info")
+
+	_, err = docker.ResolvePorts(&docker.Info{}, []docker.PortAssignment{
+		{Internal: 443},
+	})
+	c.Check(err, gc.ErrorMatches, "port 443/tcp is not published")
+}
+
+var mountFlagTests = []struct {
+	ma   docker.MountAssignment
+	flag string
+	s    string
+}{{
+	ma:   docker.MountAssignment{External: "/srv/data", Internal: "/data", Mode: "ro"},
+	flag: "-v",
+	s:    "/srv/data:/data:ro",
+}, {
+	ma:   docker.MountAssignment{Type: docker.MountBind, External: "/srv/data", Internal: "/data", ReadOnly: true},
+	flag: "--mount",
+	s:    "type=bind,source=/srv/data,target=/data,readonly",
+}, {
+	ma:   docker.MountAssignment{Type: docker.MountBind, External: "/srv/data", Internal: "/data", Relabel: "Z", Propagation: "rprivate"},
+	flag: "-v",
+	s:    "/srv/data:/data:rw,Z,rprivate",
+}, {
+	ma:   docker.MountAssignment{Type: docker.MountVolume, External: "data", Internal: "/data", ReadOnly: true, Relabel: "z", NoCopy: true},
+	flag: "-v",
+	s:    "data:/data:ro,z,nocopy",
+}, {
+	ma:   docker.MountAssignment{Type: docker.MountTmpfs, Internal: "/run", ReadOnly: true, TmpfsSize: 64 * docker.MiB},
+	flag: "--mount",
+	s:    "type=tmpfs,target=/run,readonly,tmpfs-size=64m",
+}}
+
+func (assignmentsSuite) TestMountFlag(c *gc.C) {
+	for i, test := range mountFlagTests {
+		c.Logf("test %d: %s", i, test.s)
+
+		c.Check(test.ma.Validate(), jc.ErrorIsNil)
+		c.Check(test.ma.Flag(), gc.Equals, test.flag)
+		c.Check(test.ma.String(), gc.Equals, test.s)
+	}
+}
+
+func (assignmentsSuite) TestMountValidateTyped(c *gc.C) {
+	ma := docker.MountAssignment{Type: docker.MountBind, External: "/srv", Internal: "/data", Mode: "ro"}
+	c.Check(ma.Validate(), gc.ErrorMatches, `invalid mount assignment .*: mode can't be used with a type`)
+
+	ma = docker.MountAssignment{External: "/srv", Internal: "/data", ReadOnly: true}
+	c.Check(ma.Validate(), gc.ErrorMatches, `invalid mount assignment .*: options other than the mode need a type`)
+
+	ma = docker.MountAssignment{Type: docker.MountVolume, Internal: "/data", Relabel: "z"}
+	c.Check(ma.Validate(), gc.ErrorMatches, `invalid mount assignment .*: relabel needs a named volume`)
+
+	ma = docker.MountAssignment{Type: docker.MountTmpfs, Internal: "/run", TmpfsMode: 02777}
+	c.Check(ma.Validate(), gc.ErrorMatches, `invalid mount assignment .*: bad tmpfs mode 02777`)
+}
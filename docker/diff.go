@@ -0,0 +1,319 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DiffKind describes how a container differs from the desired args.
+type DiffKind string
+
+// These are the ways in which a container may differ.
+const (
+	// DiffAdded means the args have something the container lacks.
+	DiffAdded DiffKind = "added"
+	// DiffRemoved means the container has something the args lack.
+	DiffRemoved DiffKind = "removed"
+	// DiffChanged means the args and the container disagree.
+	DiffChanged DiffKind = "changed"
+)
+
+// Difference is a single way in which a container differs from the
+// desired args.
+type Difference struct {
+	// Field is the path to the field that differs (e.g. `Env["FOO"]`).
+	Field string
+	// Kind is how the field differs.
+	Kind DiffKind
+	// Desired is the value in the args, if any.
+	Desired string
+	// Actual is the value in the container, if any.
+	Actual string
+}
+
+// String returns a description of the difference.
+func (d Difference) String() string {
+	switch d.Kind {
+	case DiffAdded:
+		return fmt.Sprintf("%s added: %q", d.Field, d.Desired)
+	case DiffRemoved:
+		return fmt.Sprintf("%s removed: %q", d.Field, d.Actual)
+	}
+	return fmt.Sprintf("%s changed: %q -> %q", d.Field, d.Actual, d.Desired)
+}
+
+// Diff compares the desired args with the container they were (or
+// might have been) run as, and returns the differences, in the order
+// of the fields in RunArgs. The container needs recreating to match
+// the args if there are any.
+//
+// Settings the args leave to the image (the entrypoint, command,
+// working directory and user) are not compared. Nor are env vars or
+// labels that the container has but the args don't, since the image
+// (or docker) may have provided them (e.g. PATH), nor the mount options
+// docker does not report (see Info.RunArgs). Where the args override
+// the entrypoint, it is compared together with the command, as a
+// single argv list. Secret values are redacted from the result.
+//
+// The image is compared by name, or by ID if the args give one, so an
+// image that has been updated under the same name is not found; use
+// DiffWithImageID for that.
+func Diff(desired RunArgs, actual *Info) ([]Difference, error) {
+	return DiffWithImageID(desired, actual, "")
+}
+
+// DiffWithImageID is like Diff, except that the container's image is
+// also compared with imageID, the ID the desired image resolves to now
+// (e.g. as reported by docker image inspect after a pull), so that an
+// image that has changed under the same name is found. Only a container
+// knows which image it was created from, so the caller must look up the
+// current one. If imageID is empty, it is the same as Diff.
+func DiffWithImageID(desired RunArgs, actual *Info, imageID string) ([]Difference, error) {
+	if actual == nil {
+		return nil, fmt.Errorf("no container to compare with")
+	}
+	cmd, err := desired.command()
+	if err != nil {
+		return nil, err
+	}
+	current, err := actual.RunArgs(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs differences
+	if desired.Name != "" {
+		diffs.compare("Name", strings.TrimPrefix(desired.Name, "/"), current.Name)
+	}
+	switch {
+	case !sameImage(desired.Image, current.Image, actual):
+		diffs.compare("Image", desired.Image, current.Image)
+	case imageID != "" && actual.ContainerJSONBase != nil && !sameImageID(imageID, actual.Image):
+		diffs.compare("Image", imageID, actual.Image)
+	}
+	if desired.Entrypoint != nil {
+		// docker run can only set the entrypoint's executable, so the
+		// rest of it may have become part of the command.
+		diffs.compare("Entrypoint",
+			fmt.Sprint(append(append([]string{}, desired.Entrypoint...), cmd...)),
+			fmt.Sprint(append(append([]string{}, current.Entrypoint...), current.Args...)))
+	} else if cmd != nil {
+		diffs.compare("Command", fmt.Sprint(cmd), fmt.Sprint(current.Args))
+	}
+	if desired.WorkDir != "" {
+		diffs.compare("WorkDir", desired.WorkDir, current.WorkDir)
+	}
+	if desired.User != "" {
+		diffs.compare("User", desired.User, current.User)
+	}
+	diffs.compareResources(desired.Resources, current.Resources)
+	diffs.compare("RestartPolicy", restartPolicyString(desired.RestartPolicy), restartPolicyString(current.RestartPolicy))
+
+	for _, key := range sortedKeys(desired.Labels) {
+		actualValue, ok := current.Labels[key]
+		diffs.compareKey(fmt.Sprintf("Labels[%q]", key), desired.Labels[key], actualValue, ok)
+	}
+	diffs.compareEnv(desired, current.Env)
+	diffs.comparePorts(desired.Ports, current.Ports)
+	diffs.compareMounts(desired.Mounts, current.Mounts)
+	return diffs, nil
+}
+
+// sameImage reports whether the desired image is the one the container
+// was run from, either by the name it was run with or by its ID.
+func sameImage(desired, name string, actual *Info) bool {
+	if desired == name {
+		return true
+	}
+	if actual.ContainerJSONBase == nil {
+		return false
+	}
+	return sameImageID(desired, actual.Image)
+}
+
+// sameImageID reports whether the image ID, or a prefix of it, is the
+// actual image ID.
+func sameImageID(id, actual string) bool {
+	if actual == "" {
+		return false
+	}
+	actual = strings.TrimPrefix(actual, "sha256:")
+	id = strings.TrimPrefix(id, "sha256:")
+	// docker accepts any unique prefix of an image ID (of at least
+	// the 12 characters it shows).
+	return len(id) >= 12 && strings.HasPrefix(actual, id)
+}
+
+// restartPolicyString returns the restart policy, treating no policy
+// as docker's default.
+func restartPolicyString(rp RestartPolicy) string {
+	if rp.Name == "" {
+		rp.Name = RestartNo
+	}
+	return rp.String()
+}
+
+// differences accumulates the differences found by Diff.
+type differences []Difference
+
+// compare records a change to the field, if the values differ.
+func (diffs *differences) compare(field, desired, actual string) {
+	if desired != actual {
+		*diffs = append(*diffs, Difference{Field: field, Kind: DiffChanged, Desired: desired, Actual: actual})
+	}
+}
+
+// compareKey records an addition or change to the keyed field, which
+// is only present in the container if found is true.
+func (diffs *differences) compareKey(field, desired, actual string, found bool) {
+	if !found {
+		*diffs = append(*diffs, Difference{Field: field, Kind: DiffAdded, Desired: desired})
+		return
+	}
+	diffs.compare(field, desired, actual)
+}
+
+// compareResources records changes to the resource limits. Limits that
+// this version of docker does not report are not compared, and nor is
+// the memory swap if the args leave it to docker.
+func (diffs *differences) compareResources(desired, actual Resources) {
+	if desired.MemorySwap == 0 {
+		actual.MemorySwap = 0
+	}
+	diffs.compare("Resources.Memory", desired.Memory.String(), actual.Memory.String())
+	diffs.compare("Resources.MemorySwap", desired.MemorySwap.String(), actual.MemorySwap.String())
+	diffs.compare("Resources.CPUShares", strconv.FormatInt(desired.CPUShares, 10), strconv.FormatInt(actual.CPUShares, 10))
+	diffs.compare("Resources.CPUPeriod", desired.CPUPeriod.String(), actual.CPUPeriod.String())
+	diffs.compare("Resources.CPUQuota", desired.CPUQuota.String(), actual.CPUQuota.String())
+	diffs.compare("Resources.CpusetCPUs", desired.CpusetCPUs, actual.CpusetCPUs)
+	diffs.compare("Resources.BlkioWeight", strconv.Itoa(int(desired.BlkioWeight)), strconv.Itoa(int(actual.BlkioWeight)))
+	diffs.compare("Resources.OOMKillDisable", strconv.FormatBool(desired.OOMKillDisable), strconv.FormatBool(actual.OOMKillDisable))
+}
+
+// compareEnv records env vars in the args that are missing from the
+// container or have a different value there.
+func (diffs *differences) compareEnv(desired RunArgs, actual EnvList) {
+	actualValues := make(map[string]string)
+	for _, ev := range actual {
+		actualValues[ev.Name] = ev.Value
+	}
+	// Later variables override earlier ones, as they do in docker.
+	var names []string
+	desiredVars := make(map[string]EnvVar)
+	for _, name := range sortedKeys(desired.EnvVars) {
+		names = append(names, name)
+		desiredVars[name] = EnvVar{Name: name, Value: desired.EnvVars[name]}
+	}
+	for _, ev := range desired.Env {
+		if _, ok := desiredVars[ev.Name]; !ok {
+			names = append(names, ev.Name)
+		}
+		desiredVars[ev.Name] = ev
+	}
+
+	for _, name := range names {
+		ev := desiredVars[name]
+		field := fmt.Sprintf("Env[%q]", name)
+		actualValue, ok := actualValues[name]
+		if !ok {
+			*diffs = append(*diffs, Difference{Field: field, Kind: DiffAdded, Desired: ev.displayValue()})
+			continue
+		}
+		if actualValue == ev.Value {
+			continue
+		}
+		// The displayed values may both be redacted, so they can't be
+		// compared.
+		actualVar := EnvVar{Value: actualValue, Secret: ev.Secret}
+		*diffs = append(*diffs, Difference{Field: field, Kind: DiffChanged, Desired: ev.displayValue(), Actual: actualVar.displayValue()})
+	}
+}
+
+// displayValue returns the variable's value, unless it is a secret.
+func (ev EnvVar) displayValue() string {
+	if ev.Secret {
+		return redacted
+	}
+	return ev.Value
+}
+
+// comparePorts records port mappings in the args that the container
+// lacks, and those the container has that the args lack. A mapping
+// that leaves docker to pick the host port matches any host port.
+func (diffs *differences) comparePorts(desired, actual []PortAssignment) {
+	matched := make([]bool, len(actual))
+	for _, pa := range desired {
+		for _, p := range pa.expand() {
+			p.Protocol = p.protocol()
+			found := false
+			for i, a := range actual {
+				if matched[i] || !samePort(p, a) {
+					continue
+				}
+				matched[i], found = true, true
+				break
+			}
+			if !found {
+				*diffs = append(*diffs, Difference{Field: "Ports", Kind: DiffAdded, Desired: p.String()})
+			}
+		}
+	}
+	for i, a := range actual {
+		if !matched[i] {
+			*diffs = append(*diffs, Difference{Field: "Ports", Kind: DiffRemoved, Actual: a.String()})
+		}
+	}
+}
+
+// sameMount reports whether the actual mount is the desired one. The
+// options docker does not report for mounts given in its long form
+// (see Info.RunArgs) are not compared.
+func sameMount(desired, actual MountAssignment) bool {
+	if desired.Flag() == "--mount" && actual.Flag() == "--mount" {
+		desired.Propagation, desired.NoCopy = "", false
+		desired.TmpfsSize, desired.TmpfsMode = 0, 0
+	}
+	return desired.String() == actual.String()
+}
+
+// samePort reports whether the actual port mapping is the desired one.
+func samePort(desired, actual PortAssignment) bool {
+	if desired.External != 0 && desired.External != actual.External {
+		return false
+	}
+	return desired.Internal == actual.Internal &&
+		desired.Protocol == actual.protocol() &&
+		desired.HostIP == actual.HostIP
+}
+
+// compareMounts records mounts in the args that the container lacks or
+// has differently, by container path, and those the container has that
+// the args lack. Anonymous volumes in the container are only compared
+// if the args ask for them, since the image may have declared them.
+func (diffs *differences) compareMounts(desired, actual []MountAssignment) {
+	actualMounts := make(map[string]MountAssignment)
+	for _, ma := range actual {
+		actualMounts[ma.Internal] = ma
+	}
+	wanted := make(map[string]bool)
+	for _, ma := range desired {
+		wanted[ma.Internal] = true
+		field := fmt.Sprintf("Mounts[%q]", ma.Internal)
+		actualMount, ok := actualMounts[ma.Internal]
+		if ok && sameMount(ma, actualMount) {
+			continue
+		}
+		diffs.compareKey(field, ma.String(), actualMount.String(), ok)
+	}
+	for _, ma := range actual {
+		if wanted[ma.Internal] || (ma.Type == MountVolume && ma.External == "") {
+			continue
+		}
+		field := fmt.Sprintf("Mounts[%q]", ma.Internal)
+		*diffs = append(*diffs, Difference{Field: field, Kind: DiffRemoved, Actual: ma.String()})
+	}
+}
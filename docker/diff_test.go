@@ -0,0 +1,289 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker_test
+
+import (
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/nat"
+	"github.com/docker/docker/runconfig"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju-process-docker/docker"
+)
+
+var _ = gc.Suite(&diffSuite{})
+
+type diffSuite struct{}
+
+func (diffSuite) TestDiffNone(c *gc.C) {
+	info := docker.Info(*fakeInfo)
+
+	diffs, err := docker.Diff(docker.RunArgs{
+		Name:    "sad_perlman",
+		Image:   "docker/whalesay",
+		Command: "sleep 30",
+	}, &info)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(diffs, gc.HasLen, 0)
+}
+
+func (diffSuite) TestDiffImageID(c *gc.C) {
+	info := docker.Info(*fakeInfo)
+
+	for _, image := range []string{
+		"fb434121fc77",
+		"sha256:fb434121fc77c965f255cbb848927f577bbdbd9325bdc1d7f1b33f99936b9abb",
+	} {
+		diffs, err := docker.Diff(docker.RunArgs{Image: image}, &info)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(diffs, gc.HasLen, 0)
+	}
+
+	diffs, err := docker.Diff(docker.RunArgs{Image: "fb43"}, &info)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(diffs, jc.DeepEquals, []docker.Difference{{
+		Field:   "Image",
+		Kind:    docker.DiffChanged,
+		Desired: "fb43",
+		Actual:  "docker/whalesay",
+	}})
+}
+
+func (diffSuite) TestDiffImageUpdated(c *gc.C) {
+	info := docker.Info(*fakeInfo)
+	args := docker.RunArgs{
+		Name:    "sad_perlman",
+		Image:   "docker/whalesay",
+		Command: "sleep 30",
+	}
+
+	for _, id := range []string{
+		"fb434121fc77",
+		"sha256:fb434121fc77c965f255cbb848927f577bbdbd9325bdc1d7f1b33f99936b9abb",
+	} {
+		diffs, err := docker.DiffWithImageID(args, &info, id)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(diffs, gc.HasLen, 0)
+	}
+
+	diffs, err := docker.DiffWithImageID(args, &info, "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(diffs, jc.DeepEquals, []docker.Difference{{
+		Field:   "Image",
+		Kind:    docker.DiffChanged,
+		Desired: "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		Actual:  info.Image,
+	}})
+}
+
+var diffInfo = docker.Info(types.ContainerJSON{
+	ContainerJSONBase: &types.ContainerJSONBase{
+		Name:  "/spam",
+		Image: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		HostConfig: &runconfig.HostConfig{
+			Binds: []string{
+				"/srv/data:/data:ro",
+				"/srv/logs:/var/log",
+			},
+			Memory:    512 << 20,
+			CPUShares: 512,
+			PortBindings: nat.PortMap{
+				"80/tcp":  {{HostPort: "8080"}},
+				"443/tcp": {{HostPort: ""}},
+				"53/udp":  {{HostPort: "5353"}},
+			},
+			RestartPolicy: runconfig.RestartPolicy{Name: "always"},
+		},
+	},
+	Mounts: []types.MountPoint{
+		{Name: "0123abcd", Destination: "/var/lib/spam", RW: true},
+	},
+	Config: &runconfig.Config{
+		Image:      "my-spam@sha256:aaaa",
+		Cmd:        runconfig.NewCommand("spam", "--serve"),
+		WorkingDir: "/srv",
+		Env: []string{
+			"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+			"FOO=bar",
+			"PASSWORD=hunter2",
+		},
+		Labels: map[string]string{
+			"com.canonical.juju.unit": "spam/0",
+			"maintainer":              "someone",
+		},
+		Volumes: map[string]struct{}{
+			"/var/lib/spam": {},
+		},
+	},
+})
+
+func (diffSuite) TestDiff(c *gc.C) {
+	diffs, err := docker.Diff(docker.RunArgs{
+		Name:    "spam",
+		Image:   "my-spam@sha256:bbbb",
+		Args:    []string{"spam", "--serve"},
+		WorkDir: "/srv",
+		Resources: docker.Resources{
+			Memory:    1 * docker.GiB,
+			CPUShares: 512,
+		},
+		Labels: map[string]string{
+			"com.canonical.juju.unit":       "spam/1",
+			"com.canonical.juju.model-uuid": "deadbeef",
+		},
+		EnvVars: map[string]string{
+			"FOO": "baz",
+			"BAR": "1",
+		},
+		Env: docker.EnvList{
+			{Name: "PASSWORD", Value: "swordfish", Secret: true},
+			{Name: "TOKEN", Value: "xyzzy", Secret: true},
+		},
+		Ports: []docker.PortAssignment{
+			{External: 8080, Internal: 80},
+			{Internal: 443},
+			{External: 9000, Internal: 90},
+		},
+		Mounts: []docker.MountAssignment{
+			{External: "/srv/data", Internal: "/data", Mode: "ro"},
+			{External: "/srv/cache", Internal: "/cache"},
+		},
+	}, &diffInfo)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(diffs, jc.DeepEquals, []docker.Difference{
+		{Field: "Image", Kind: docker.DiffChanged, Desired: "my-spam@sha256:bbbb", Actual: "my-spam@sha256:aaaa"},
+		{Field: "Resources.Memory", Kind: docker.DiffChanged, Desired: "1g", Actual: "512m"},
+		{Field: "RestartPolicy", Kind: docker.DiffChanged, Desired: "no", Actual: "always"},
+		{Field: `Labels["com.canonical.juju.model-uuid"]`, Kind: docker.DiffAdded, Desired: "deadbeef"},
+		{Field: `Labels["com.canonical.juju.unit"]`, Kind: docker.DiffChanged, Desired: "spam/1", Actual: "spam/0"},
+		{Field: `Env["BAR"]`, Kind: docker.DiffAdded, Desired: "1"},
+		{Field: `Env["FOO"]`, Kind: docker.DiffChanged, Desired: "baz", Actual: "bar"},
+		{Field: `Env["PASSWORD"]`, Kind: docker.DiffChanged, Desired: "<redacted>", Actual: "<redacted>"},
+		{Field: `Env["TOKEN"]`, Kind: docker.DiffAdded, Desired: "<redacted>"},
+		{Field: "Ports", Kind: docker.DiffAdded, Desired: "9000:90/tcp"},
+		{Field: "Ports", Kind: docker.DiffRemoved, Actual: "5353:53/udp"},
+		{Field: `Mounts["/cache"]`, Kind: docker.DiffAdded, Desired: "/srv/cache:/cache"},
+		{Field: `Mounts["/var/log"]`, Kind: docker.DiffRemoved, Actual: "/srv/logs:/var/log"},
+	})
+}
+
+func (diffSuite) TestDiffImageDefaults(c *gc.C) {
+	diffs, err := docker.Diff(docker.RunArgs{
+		Image:         "my-spam@sha256:aaaa",
+		Resources:     docker.Resources{Memory: 512 * docker.MiB, CPUShares: 512},
+		RestartPolicy: docker.RestartPolicy{Name: docker.RestartAlways},
+		Ports: []docker.PortAssignment{
+			{External: 8080, Internal: 80, Protocol: "tcp"},
+			{Internal: 443},
+			{External: 5353, Internal: 53, Protocol: "udp"},
+		},
+		Mounts: []docker.MountAssignment{
+			{External: "/srv/data", Internal: "/data", Mode: "ro"},
+			{External: "/srv/logs", Internal: "/var/log"},
+		},
+	}, &diffInfo)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(diffs, gc.HasLen, 0)
+}
+
+func (diffSuite) TestDiffTypedMounts(c *gc.C) {
+	info := docker.Info(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			Name:       "/spam",
+			HostConfig: &runconfig.HostConfig{},
+		},
+		Mounts: []types.MountPoint{
+			{Source: "/srv/data", Destination: "/data"},
+			{Destination: "/run", RW: true},
+			{Destination: "/tmp", RW: true},
+		},
+		Config: &runconfig.Config{Image: "my-spam"},
+	})
+
+	diffs, err := docker.Diff(docker.RunArgs{
+		Image: "my-spam",
+		Mounts: []docker.MountAssignment{
+			{Type: docker.MountBind, External: "/srv/data", Internal: "/data", ReadOnly: true},
+			{Type: docker.MountTmpfs, Internal: "/run", TmpfsSize: 64 * docker.MiB, TmpfsMode: 01770},
+			{Type: docker.MountTmpfs, Internal: "/tmp", ReadOnly: true},
+		},
+	}, &info)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(diffs, jc.DeepEquals, []docker.Difference{{
+		Field:   `Mounts["/tmp"]`,
+		Kind:    docker.DiffChanged,
+		Desired: "type=tmpfs,target=/tmp,readonly",
+		Actual:  "type=tmpfs,target=/tmp",
+	}})
+}
+
+func (diffSuite) TestDiffEntrypoint(c *gc.C) {
+	// docker run --entrypoint only takes the executable, so the rest
+	// of the entrypoint is passed with the command.
+	info := docker.Info(types.ContainerJSON{
+		Config: &runconfig.Config{
+			Image:      "my-spam",
+			Entrypoint: runconfig.NewEntrypoint("/bin/sh"),
+			Cmd:        runconfig.NewCommand("-c", "spam --serve"),
+		},
+	})
+
+	diffs, err := docker.Diff(docker.RunArgs{
+		Image:      "my-spam",
+		Entrypoint: []string{"/bin/sh", "-c"},
+		Args:       []string{"spam --serve"},
+	}, &info)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(diffs, gc.HasLen, 0)
+
+	diffs, err = docker.Diff(docker.RunArgs{
+		Image:      "my-spam",
+		Entrypoint: []string{"/bin/sh", "-c"},
+		Args:       []string{"spam --debug"},
+	}, &info)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(diffs, jc.DeepEquals, []docker.Difference{{
+		Field:   "Entrypoint",
+		Kind:    docker.DiffChanged,
+		Desired: "[/bin/sh -c spam --debug]",
+		Actual:  "[/bin/sh -c spam --serve]",
+	}})
+}
+
+func (diffSuite) TestDiffBadCommand(c *gc.C) {
+	_, err := docker.Diff(docker.RunArgs{
+		Image:   "my-spam",
+		Command: `sh -c "echo`,
+	}, &diffInfo)
+	c.Check(err, gc.ErrorMatches, `invalid command .*: unterminated double quote`)
+}
+
+func (diffSuite) TestDiffNoContainer(c *gc.C) {
+	_, err := docker.Diff(docker.RunArgs{Image: "my-spam"}, nil)
+	c.Check(err, gc.ErrorMatches, "no container to compare with")
+}
+
+func (diffSuite) TestDifferenceString(c *gc.C) {
+	c.Check(docker.Difference{
+		Field:   `Env["FOO"]`,
+		Kind:    docker.DiffAdded,
+		Desired: "bar",
+	}.String(), gc.Equals, `Env["FOO"] added: "bar"`)
+	c.Check(docker.Difference{
+		Field:  "Ports",
+		Kind:   docker.DiffRemoved,
+		Actual: "5353:53/udp",
+	}.String(), gc.Equals, `Ports removed: "5353:53/udp"`)
+	c.Check(docker.Difference{
+		Field:   "Resources.Memory",
+		Kind:    docker.DiffChanged,
+		Desired: "1g",
+		Actual:  "512m",
+	}.String(), gc.Equals, `Resources.Memory changed: "512m" -> "1g"`)
+}
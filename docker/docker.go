@@ -7,6 +7,15 @@ package docker
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Client represents a client to docker's API.
@@ -17,41 +26,147 @@ type Client interface {
 	// Inspect gets info about the given container ID (or name).
 	Inspect(id string) (*Info, error)
 
+	// InspectMany gets info about each of the given container IDs (or
+	// names). There is one result per ID, in the same order, holding
+	// either the info or the reason the container couldn't be
+	// inspected. The error is only for failures that affect them all.
+	InspectMany(ids ...string) ([]InspectResult, error)
+
 	// Stop stops the identified container.
 	Stop(id string) error
 
 	// Remove removes the identified container.
 	Remove(id string) error
+
+	// StopWithArgs stops the identified container as directed by the
+	// args, and reports how it came to exit.
+	StopWithArgs(id string, args StopArgs) (*StopResult, error)
+
+	// RemoveWithArgs removes the identified container as directed by
+	// the args.
+	RemoveWithArgs(id string, args RemoveArgs) error
+
+	// Start starts the identified (stopped) container.
+	Start(id string) error
+
+	// Restart stops and then starts the identified container, giving
+	// it the timeout to stop before it is killed.
+	Restart(id string, timeout time.Duration) error
+
+	// Kill sends the signal (e.g. "SIGINT") to the main process of the
+	// identified container. If no signal is given then SIGKILL is sent.
+	Kill(id string, signal string) error
+
+	// Pause suspends all processes in the identified container.
+	Pause(id string) error
+
+	// Unpause resumes all processes in the identified container.
+	Unpause(id string) error
+
+	// Logs returns the output of the identified container (stdout and
+	// stderr combined), as directed by the args. The caller must close
+	// the returned reader, which stops any streaming.
+	Logs(id string, args LogsArgs) (io.ReadCloser, error)
+
+	// Exec runs a command in the identified (running) container.
+	Exec(id string, args ExecArgs) (*ExecResult, error)
+
+	// Wait blocks until the identified container stops, and then
+	// reports how its main process exited.
+	Wait(ctx context.Context, id string) (*ExitStatus, error)
+
+	// WaitUntil blocks until the identified container meets the
+	// condition, or the context is done.
+	WaitUntil(ctx context.Context, id string, cond WaitCondition) error
+
+	// List returns summaries of the containers that match the args.
+	List(args ListArgs) ([]ContainerSummary, error)
+
+	// FindByLabels returns summaries of all the containers (running
+	// or not) that have every one of the labels. An empty value
+	// matches any container with the label.
+	FindByLabels(labels map[string]string) ([]ContainerSummary, error)
+
+	// RunContext is like Run, but gives up when the context is done.
+	RunContext(ctx context.Context, args RunArgs) (string, error)
+
+	// InspectContext is like Inspect, but gives up when the context
+	// is done.
+	InspectContext(ctx context.Context, id string) (*Info, error)
+
+	// StopContext is like Stop, but gives up when the context is done.
+	StopContext(ctx context.Context, id string) error
+
+	// RemoveContext is like Remove, but gives up when the context
+	// is done.
+	RemoveContext(ctx context.Context, id string) error
 }
 
 // CLIClient is a Client that wraps CLI execution of the docker command.
 type CLIClient struct {
-	// RunDocker executes the provided docker sub-command and args.
-	RunDocker func(string, ...string) ([]byte, error)
+	// RunDocker executes the provided docker sub-command and args. The
+	// command is killed if the context is done before it completes.
+	RunDocker func(context.Context, string, ...string) ([]byte, error)
+
+	// StreamDocker executes the provided docker sub-command and args,
+	// attached to the given streams. The command is killed if the
+	// context is done before it completes.
+	StreamDocker func(context.Context, Streams, string, ...string) error
 }
 
 // NewCLIClient returns a new CLIClient.
 func NewCLIClient() *CLIClient {
 	cli := &CLIClient{
-		RunDocker: runDocker,
+		RunDocker:    runDocker,
+		StreamDocker: streamDocker,
 	}
 	return cli
 }
 
 // Run runs a new docker container with the given info.
 func (cli *CLIClient) Run(args RunArgs) (string, error) {
-	cmdArgs := args.CommandlineArgs()
-	out, err := cli.RunDocker("run", cmdArgs...)
+	return cli.RunContext(context.Background(), args)
+}
+
+// RunContext is like Run, but gives up when the context is done.
+func (cli *CLIClient) RunContext(ctx context.Context, args RunArgs) (string, error) {
+	if err := args.Validate(); err != nil {
+		return "", err
+	}
+
+	var envFile string
+	if args.useEnvFile() {
+		env, err := args.env()
+		if err != nil {
+			return "", err
+		}
+		envFile, err = writeEnvFile(env)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(envFile)
+	}
+
+	cmdArgs, err := args.commandlineArgs(envFile)
 	if err != nil {
 		return "", err
 	}
+	out, err := cli.run(ctx, "run", cmdArgs...)
+	if err != nil {
+		return "", redactError(err, args.secrets())
+	}
 	id := string(bytes.TrimSpace(out))
 	return id, nil
 }
 
 // Inspect gets info about the given container ID (or name).
 func (cli *CLIClient) Inspect(id string) (*Info, error) {
-	out, err := cli.RunDocker("inspect", id)
+	return cli.InspectContext(context.Background(), id)
+}
+
+// InspectContext is like Inspect, but gives up when the context is done.
+func (cli *CLIClient) InspectContext(ctx context.Context, id string) (*Info, error) {
+	out, err := cli.run(ctx, "inspect", id)
 	if err != nil {
 		return nil, err
 	}
@@ -63,9 +178,87 @@ func (cli *CLIClient) Inspect(id string) (*Info, error) {
 	return info, nil
 }
 
+// InspectMany gets info about each of the given container IDs (or
+// names), using a single docker command. There is one result per ID,
+// in the same order.
+func (cli *CLIClient) InspectMany(ids ...string) ([]InspectResult, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	streams := Streams{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	err := cli.StreamDocker(context.Background(), streams, "inspect", ids...)
+
+	// docker inspect reports any containers that were not found on
+	// stderr, while still printing the ones that were.
+	var missing map[string]error
+	if err != nil {
+		missing = missingContainers(stderr.String())
+		if missing == nil {
+			return nil, classifyCLIError(err)
+		}
+	}
+
+	var found []string
+	for _, id := range ids {
+		if missing[id] == nil {
+			found = append(found, id)
+		}
+	}
+	infos, err := ParseInfosJSON(ids, stdout.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) != len(found) {
+		return nil, fmt.Errorf("expected %d status values from docker inspect %s, got %d", len(found), strings.Join(ids, " "), len(infos))
+	}
+
+	results := make([]InspectResult, len(ids))
+	for i, id := range ids {
+		results[i].ID = id
+		if err := missing[id]; err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Info, infos = infos[0], infos[1:]
+	}
+	return results, nil
+}
+
+// missingContainers returns the error for each container that docker
+// inspect reported as not found, keyed by ID. If any other failure was
+// reported then it returns nil.
+func missingContainers(stderr string) map[string]error {
+	missing := make(map[string]error)
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		i := strings.LastIndex(line, ": ")
+		if i < 0 || classifyMessage(line) != ErrNotFound {
+			return nil
+		}
+		missing[line[i+2:]] = &Error{Kind: ErrNotFound, Err: errors.New(line)}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return missing
+}
+
 // Stop stops the identified container.
 func (cli *CLIClient) Stop(id string) error {
-	if _, err := cli.RunDocker("stop", id); err != nil {
+	return cli.StopContext(context.Background(), id)
+}
+
+// StopContext is like Stop, but gives up when the context is done.
+func (cli *CLIClient) StopContext(ctx context.Context, id string) error {
+	if _, err := cli.run(ctx, "stop", id); err != nil {
 		return err
 	}
 	return nil
@@ -73,8 +266,228 @@ func (cli *CLIClient) Stop(id string) error {
 
 // Remove removes the identified container.
 func (cli *CLIClient) Remove(id string) error {
-	if _, err := cli.RunDocker("rm", id); err != nil {
+	return cli.RemoveContext(context.Background(), id)
+}
+
+// RemoveContext is like Remove, but gives up when the context is done.
+func (cli *CLIClient) RemoveContext(ctx context.Context, id string) error {
+	if _, err := cli.run(ctx, "rm", id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// StopWithArgs stops the identified container as directed by the args,
+// and reports how it came to exit.
+func (cli *CLIClient) StopWithArgs(id string, args StopArgs) (*StopResult, error) {
+	cmdArgs := append(args.CommandlineArgs(), id)
+	if _, err := cli.run(context.Background(), "stop", cmdArgs...); err != nil {
+		return nil, err
+	}
+
+	info, err := cli.Inspect(id)
+	if err != nil {
+		return nil, err
+	}
+	return newStopResult(info), nil
+}
+
+// RemoveWithArgs removes the identified container as directed by the
+// args.
+func (cli *CLIClient) RemoveWithArgs(id string, args RemoveArgs) error {
+	cmdArgs := append(args.CommandlineArgs(), id)
+	if _, err := cli.run(context.Background(), "rm", cmdArgs...); err != nil {
+		if args.IgnoreNotFound && IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Start starts the identified (stopped) container.
+func (cli *CLIClient) Start(id string) error {
+	if _, err := cli.run(context.Background(), "start", id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Restart stops and then starts the identified container, giving it
+// the timeout to stop before it is killed.
+func (cli *CLIClient) Restart(id string, timeout time.Duration) error {
+	if _, err := cli.run(context.Background(), "restart", "--time", formatSeconds(timeout), id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Kill sends the signal (e.g. "SIGINT") to the main process of the
+// identified container. If no signal is given then SIGKILL is sent.
+func (cli *CLIClient) Kill(id string, signal string) error {
+	var args []string
+	if signal != "" {
+		args = append(args, "--signal", signal)
+	}
+	if _, err := cli.run(context.Background(), "kill", append(args, id)...); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Pause suspends all processes in the identified container.
+func (cli *CLIClient) Pause(id string) error {
+	if _, err := cli.run(context.Background(), "pause", id); err != nil {
 		return err
 	}
 	return nil
 }
+
+// Unpause resumes all processes in the identified container.
+func (cli *CLIClient) Unpause(id string) error {
+	if _, err := cli.run(context.Background(), "unpause", id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Logs returns the output of the identified container (stdout and
+// stderr combined), as directed by the args. The caller must close the
+// returned reader, which stops any streaming. Any failure of the
+// docker command is reported when reading.
+func (cli *CLIClient) Logs(id string, args LogsArgs) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r, w := io.Pipe()
+	cmdArgs := append(args.CommandlineArgs(), id)
+	go func() {
+		streams := Streams{
+			Stdout: w,
+			Stderr: w,
+		}
+		err := cli.StreamDocker(ctx, streams, "logs", cmdArgs...)
+		if err != nil {
+			err = classifyCLIError(err)
+		}
+		w.CloseWithError(err)
+	}()
+	return &cancelReader{ReadCloser: r, cancel: cancel}, nil
+}
+
+// Exec runs a command in the identified (running) container. A non-zero
+// exit code from the command is reported in the result rather than as
+// an error.
+func (cli *CLIClient) Exec(id string, args ExecArgs) (*ExecResult, error) {
+	if args.TTY && args.Stdin != nil {
+		// docker exec --interactive --tty refuses input that isn't
+		// from a terminal.
+		return nil, fmt.Errorf("can't exec in container %s with both a TTY and stdin", id)
+	}
+	var stdout, stderr bytes.Buffer
+	streams := Streams{
+		Stdin:  args.Stdin,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	err := cli.StreamDocker(context.Background(), streams, "exec", args.CommandlineArgs(id)...)
+
+	// docker exec exits with the command's exit code, so it's only a
+	// failure if docker itself had a problem.
+	var exitErr interface {
+		ExitCode() int
+	}
+	if err != nil && (!errors.As(err, &exitErr) || isDockerFailure(stderr.Bytes())) {
+		return nil, classifyCLIError(err)
+	}
+
+	result := &ExecResult{
+		Stdout: stdout.Bytes(),
+		Stderr: stderr.Bytes(),
+	}
+	if err != nil {
+		result.ExitCode = exitErr.ExitCode()
+	}
+	return result, nil
+}
+
+// isDockerFailure reports whether the stderr output of a docker command
+// that ran another command (e.g. docker exec) comes from docker itself,
+// rather than from the other command.
+func isDockerFailure(stderr []byte) bool {
+	msg := strings.TrimPrefix(string(bytes.TrimSpace(stderr)), "docker: ")
+	if strings.HasPrefix(msg, "Error response from daemon:") {
+		return true
+	}
+	switch classifyMessage(msg) {
+	case ErrDaemonUnavailable, ErrPermissionDenied:
+		return true
+	}
+	return false
+}
+
+// Wait blocks until the identified container stops, and then reports
+// how its main process exited.
+func (cli *CLIClient) Wait(ctx context.Context, id string) (*ExitStatus, error) {
+	out, err := cli.run(ctx, "wait", id)
+	if err != nil {
+		return nil, err
+	}
+	exitCode, err := strconv.Atoi(string(bytes.TrimSpace(out)))
+	if err != nil {
+		return nil, fmt.Errorf("can't decode response from docker wait %s: %s", id, err)
+	}
+
+	info, err := cli.InspectContext(ctx, id)
+	if IsNotFound(err) {
+		// The container was removed as soon as it stopped.
+		return newExitStatus(exitCode, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newExitStatus(exitCode, info)
+}
+
+// WaitUntil blocks until the identified container meets the condition,
+// or the context is done.
+func (cli *CLIClient) WaitUntil(ctx context.Context, id string, cond WaitCondition) error {
+	return waitUntil(ctx, id, cond, func(ctx context.Context) (*containerStatus, error) {
+		out, err := cli.run(ctx, "inspect", "--format", "{{json .State}}", id)
+		if err != nil {
+			return nil, err
+		}
+		var status containerStatus
+		if err := json.Unmarshal(out, &status); err != nil {
+			return nil, fmt.Errorf("can't decode response from docker inspect %s: %s", id, err)
+		}
+		return &status, nil
+	})
+}
+
+// List returns summaries of the containers that match the args.
+func (cli *CLIClient) List(args ListArgs) ([]ContainerSummary, error) {
+	cmdArgs := append([]string{"--no-trunc", "--format", "{{json .}}"}, args.CommandlineArgs()...)
+	out, err := cli.run(context.Background(), "ps", cmdArgs...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseListJSON(out)
+}
+
+// FindByLabels returns summaries of all the containers (running or
+// not) that have every one of the labels.
+func (cli *CLIClient) FindByLabels(labels map[string]string) ([]ContainerSummary, error) {
+	if len(labels) == 0 {
+		return nil, errNoLabels
+	}
+	return cli.List(ListArgs{All: true, Labels: labels})
+}
+
+// run executes the provided docker sub-command and args, classifying
+// any failure.
+func (cli *CLIClient) run(ctx context.Context, command string, args ...string) ([]byte, error) {
+	out, err := cli.RunDocker(ctx, command, args...)
+	if err != nil {
+		return nil, classifyCLIError(err)
+	}
+	return out, nil
+}
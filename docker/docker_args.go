@@ -5,39 +5,272 @@ package docker
 
 import (
 	"fmt"
+	"io"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // PortAssignment describes a port mapping between the host
 // and the container.
 type PortAssignment struct {
-	// External is the port on the host.
+	// HostIP is the host address to bind the port to (optional). If
+	// empty, the port is bound on every address.
+	HostIP string
+	// External is the port on the host. If zero, docker picks a free
+	// port when the container starts (see ResolvePorts).
 	External int
 	// Internal is the port on the container.
 	Internal int
+	// Count is the number of consecutive ports that are mapped,
+	// starting at External and Internal. Zero means one.
+	Count int
 	// Protocol is the network protocol for the mapping (e.g. tcp, udp).
+	// If empty, docker uses tcp.
 	Protocol string
 }
 
 // String returns a docker-friendly string representation of the mapping.
 func (pa PortAssignment) String() string {
-	return fmt.Sprintf("%d:%d/%s", pa.External, pa.Internal, pa.Protocol)
+	var s string
+	if pa.HostIP != "" {
+		host := pa.HostIP
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		s = host + ":"
+		if pa.External != 0 {
+			s += portRange(pa.External, pa.Count)
+		}
+		s += ":"
+	} else if pa.External != 0 {
+		s = portRange(pa.External, pa.Count) + ":"
+	}
+	s += portRange(pa.Internal, pa.Count)
+	if pa.Protocol != "" {
+		s += "/" + pa.Protocol
+	}
+	return s
+}
+
+// portRange returns the range of count ports from the first, in
+// docker syntax (e.g. "7000-7010").
+func portRange(first, count int) string {
+	if count <= 1 {
+		return strconv.Itoa(first)
+	}
+	return fmt.Sprintf("%d-%d", first, first+count-1)
+}
+
+// expand returns the mapping as one PortAssignment per port.
+func (pa PortAssignment) expand() []PortAssignment {
+	if pa.Count <= 1 {
+		pa.Count = 0
+		return []PortAssignment{pa}
+	}
+	var ports []PortAssignment
+	for i := 0; i < pa.Count; i++ {
+		port := PortAssignment{
+			HostIP:   pa.HostIP,
+			Internal: pa.Internal + i,
+			Protocol: pa.Protocol,
+		}
+		if pa.External != 0 {
+			port.External = pa.External + i
+		}
+		ports = append(ports, port)
+	}
+	return ports
 }
 
+// MountType identifies the kind of storage that is mounted into a
+// container.
+type MountType string
+
+// These are the kinds of mount docker supports.
+const (
+	// MountBind mounts a path on the host.
+	MountBind MountType = "bind"
+	// MountVolume mounts a volume managed by docker.
+	MountVolume MountType = "volume"
+	// MountTmpfs mounts a temporary filesystem held in memory.
+	MountTmpfs MountType = "tmpfs"
+)
+
 // MountAssignment describes a volume mount mapping between the host
 // and the container.
+//
+// If Type is empty, the mount is given in docker's shorthand
+// (External:Internal:Mode), where External is a path on the host or the
+// name of a volume. Otherwise the mount is described by the fields that
+// follow Mode, and Mode must be empty.
 type MountAssignment struct {
-	// External is the volume mount point on the host.
+	// External is the volume mount point on the host, or the name of
+	// a volume. For a MountVolume it may be empty, in which case an
+	// anonymous volume is created. It is not used for a MountTmpfs.
 	External string
 	// Internal is the volume mount point on the container.
 	Internal string
-	// Mode is the docker-recognized access mode (e.g. rw, ro).
+	// Mode is the docker-recognized access mode (e.g. rw, ro). If
+	// empty, docker uses rw.
 	Mode string
+
+	// Type is the kind of mount (optional).
+	Type MountType
+	// ReadOnly indicates that the container may not write to the mount.
+	ReadOnly bool
+	// Propagation is the bind propagation mode for a MountBind
+	// (e.g. rslave).
+	Propagation string
+	// Relabel is the SELinux relabelling for a MountBind or MountVolume:
+	// "z" if the content is shared between containers, or "Z" if it is
+	// private to this container.
+	Relabel string
+	// NoCopy indicates that a new MountVolume should not be populated
+	// with the image's content at the mount point.
+	NoCopy bool
+	// TmpfsSize is the size of a MountTmpfs. If zero, it is unlimited.
+	TmpfsSize ByteSize
+	// TmpfsMode is the file mode of a MountTmpfs, as unix mode bits
+	// (e.g. 01777). If zero, docker uses 1777.
+	TmpfsMode uint32
 }
 
-// String returns a docker-friendly string representation of the mapping.
+// String returns a docker-friendly string representation of the mapping,
+// in the form the flag returned by Flag expects.
 func (ma MountAssignment) String() string {
-	return fmt.Sprintf("%s:%s:%s", ma.External, ma.Internal, ma.Mode)
+	if ma.Flag() == "--mount" {
+		return ma.longForm()
+	}
+	if ma.Type != "" {
+		return ma.External + ":" + ma.Internal + ":" + strings.Join(ma.options(), ",")
+	}
+	s := fmt.Sprintf("%s:%s", ma.External, ma.Internal)
+	if ma.Mode != "" {
+		s += ":" + ma.Mode
+	}
+	return s
+}
+
+// Flag returns the docker run flag for the mount: "-v" for docker's
+// shorthand, or "--mount" for the long form. The long form is used
+// wherever it can express the mount, since the shorthand can't
+// describe a MountTmpfs and the long form can't request relabelling.
+func (ma MountAssignment) Flag() string {
+	if ma.Type == "" || ma.Relabel != "" {
+		return "-v"
+	}
+	return "--mount"
+}
+
+// options returns the mount's options in docker's shorthand.
+func (ma MountAssignment) options() []string {
+	options := []string{"rw"}
+	if ma.ReadOnly {
+		options[0] = "ro"
+	}
+	if ma.Relabel != "" {
+		options = append(options, ma.Relabel)
+	}
+	if ma.Propagation != "" {
+		options = append(options, ma.Propagation)
+	}
+	if ma.NoCopy {
+		options = append(options, "nocopy")
+	}
+	return options
+}
+
+// longForm returns the mount in docker's long form
+// (e.g. "type=bind,source=/srv,target=/data,readonly").
+func (ma MountAssignment) longForm() string {
+	fields := []string{"type=" + string(ma.Type)}
+	if ma.External != "" {
+		fields = append(fields, "source="+ma.External)
+	}
+	fields = append(fields, "target="+ma.Internal)
+	if ma.ReadOnly {
+		fields = append(fields, "readonly")
+	}
+	if ma.Propagation != "" {
+		fields = append(fields, "bind-propagation="+ma.Propagation)
+	}
+	if ma.NoCopy {
+		fields = append(fields, "volume-nocopy")
+	}
+	if ma.TmpfsSize != 0 {
+		fields = append(fields, "tmpfs-size="+ma.TmpfsSize.String())
+	}
+	if ma.TmpfsMode != 0 {
+		fields = append(fields, "tmpfs-mode="+strconv.FormatUint(uint64(ma.TmpfsMode), 8))
+	}
+	return strings.Join(fields, ",")
+}
+
+// EnvVar is a single environment variable.
+type EnvVar struct {
+	// Name is the variable's name.
+	Name string
+	// Value is the variable's value.
+	Value string
+	// Secret indicates that the value must not be exposed, so it is
+	// never passed on the docker command line (see RunArgs.EnvFile)
+	// and is redacted from error messages.
+	Secret bool
+}
+
+// redacted replaces a secret value when showing it.
+const redacted = "<redacted>"
+
+// String returns the variable in the form docker accepts, unless it is
+// a secret, in which case the value is redacted.
+func (ev EnvVar) String() string {
+	if ev.Secret {
+		return ev.Name + "=" + redacted
+	}
+	return ev.pair()
+}
+
+// pair returns the variable in the form docker accepts.
+func (ev EnvVar) pair() string {
+	return ev.Name + "=" + ev.Value
+}
+
+// EnvList is an ordered list of environment variables. Where a name
+// appears more than once, the later value overrides the earlier ones.
+type EnvList []EnvVar
+
+// Strings returns the variables in the form docker accepts, in order.
+// Secret values are included.
+func (el EnvList) Strings() []string {
+	var env []string
+	for _, ev := range el {
+		env = append(env, ev.pair())
+	}
+	return env
+}
+
+// Validate checks that each variable has a name docker will accept.
+func (el EnvList) Validate() error {
+	for _, ev := range el {
+		if err := validateEnvName(ev.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateEnvName checks that docker will accept the name of an
+// environment variable.
+func validateEnvName(name string) error {
+	if name == "" {
+		return fmt.Errorf("invalid env var name %q: must not be empty", name)
+	}
+	if strings.Contains(name, "=") {
+		return fmt.Errorf("invalid env var name %q: must not contain %q", name, "=")
+	}
+	return nil
 }
 
 // RunArgs contains the data passed to the Run function.
@@ -46,11 +279,42 @@ type RunArgs struct {
 	Name string
 	// Image is the container image to use.
 	Image string
-	// Command is the command to run in the container (optional).
+	// Command is the command to run in the container (optional). It is
+	// split into arguments following the quoting rules of a POSIX
+	// shell (see SplitCommand).
 	Command string
+	// Args is the command to run in the container, as an argv list
+	// (optional). It may be used instead of Command.
+	Args []string
+	// Entrypoint overrides the image's entrypoint, as an argv list
+	// (optional).
+	Entrypoint []string
+	// WorkDir overrides the image's working directory for the command
+	// (optional).
+	WorkDir string
+	// User overrides the image's user (name or UID, with an optional
+	// group) that the command runs as (optional).
+	User string
+	// Resources holds the limits on the resources the container may
+	// use, if any.
+	Resources Resources
+	// RestartPolicy describes when docker restarts the container after
+	// it exits (optional).
+	RestartPolicy RestartPolicy
+	// Labels holds the labels to set on the container, if any.
+	Labels map[string]string
 	// EnvVars holds the environment variables to use in the container,
-	// if any.
+	// if any. They are passed to docker sorted by name.
 	EnvVars map[string]string
+	// Env holds more environment variables to use in the container,
+	// if any. They are passed to docker in order, after EnvVars, so
+	// they override any with the same name there.
+	Env EnvList
+	// EnvFile indicates that CLIClient.Run should pass the environment
+	// variables to docker in a private temporary file, rather than on
+	// the command line where other users of the host can see them. It
+	// is implied if any of the variables in Env is a secret.
+	EnvFile bool
 	// Ports holds the ports info to map into the container from the
 	// host, if any.
 	Ports []PortAssignment
@@ -60,8 +324,45 @@ type RunArgs struct {
 }
 
 // CommandlineArgs converts the RunArgs into a list of strings that may
-// be passed to exec.Command as the command args.
+// be passed to exec.Command as the command args. All the environment
+// variables, including secrets, are passed as options. The args must be
+// valid, otherwise the result is nil; use ValidCommandlineArgs to find
+// out why.
 func (ra RunArgs) CommandlineArgs() []string {
+	args, err := ra.ValidCommandlineArgs()
+	if err != nil {
+		return nil
+	}
+	return args
+}
+
+// ValidCommandlineArgs is like CommandlineArgs, except that it returns
+// an error (see Validate) if the args are invalid.
+func (ra RunArgs) ValidCommandlineArgs() ([]string, error) {
+	if err := ra.Validate(); err != nil {
+		return nil, err
+	}
+	return ra.commandlineArgs("")
+}
+
+// commandlineArgs is like CommandlineArgs, except that the environment
+// variables are read from the env file instead, if one is given.
+func (ra RunArgs) commandlineArgs(envFile string) ([]string, error) {
+	cmd, err := ra.command()
+	if err != nil {
+		return nil, err
+	}
+	env, err := ra.env()
+	if err != nil {
+		return nil, err
+	}
+	if err := ra.Resources.Validate(); err != nil {
+		return nil, err
+	}
+	if err := ra.RestartPolicy.Validate(); err != nil {
+		return nil, err
+	}
+
 	args := []string{
 		"--detach",
 	}
@@ -70,8 +371,37 @@ func (ra RunArgs) CommandlineArgs() []string {
 		args = append(args, "--name", ra.Name)
 	}
 
-	for k, v := range ra.EnvVars {
-		args = append(args, "-e", k+"="+v)
+	// docker only accepts the executable as the entrypoint, so any
+	// arguments to it go before the command.
+	if len(ra.Entrypoint) > 0 {
+		args = append(args, "--entrypoint", ra.Entrypoint[0])
+		cmd = append(append([]string{}, ra.Entrypoint[1:]...), cmd...)
+	}
+
+	if ra.WorkDir != "" {
+		args = append(args, "--workdir", ra.WorkDir)
+	}
+
+	if ra.User != "" {
+		args = append(args, "--user", ra.User)
+	}
+
+	args = append(args, ra.Resources.CommandlineArgs()...)
+
+	if ra.RestartPolicy.Name != "" {
+		args = append(args, "--restart", ra.RestartPolicy.String())
+	}
+
+	for _, label := range sortedEnv(ra.Labels) {
+		args = append(args, "--label", label)
+	}
+
+	if envFile != "" {
+		args = append(args, "--env-file", envFile)
+	} else {
+		for _, ev := range env {
+			args = append(args, "-e", ev)
+		}
 	}
 
 	for _, p := range ra.Ports {
@@ -79,15 +409,346 @@ func (ra RunArgs) CommandlineArgs() []string {
 	}
 
 	for _, m := range ra.Mounts {
-		args = append(args, "-v", m.String())
+		args = append(args, m.Flag(), m.String())
 	}
 
 	// Image and Command must come after all options.
 	args = append(args, ra.Image)
 
-	if ra.Command != "" {
-		args = append(args, strings.Fields(ra.Command)...)
+	args = append(args, cmd...)
+
+	return args, nil
+}
+
+// env returns the environment variables to use in the container, in
+// the order they should be passed to docker.
+func (ra RunArgs) env() ([]string, error) {
+	for name := range ra.EnvVars {
+		if err := validateEnvName(name); err != nil {
+			return nil, err
+		}
+	}
+	if err := ra.Env.Validate(); err != nil {
+		return nil, err
+	}
+	return append(sortedEnv(ra.EnvVars), ra.Env.Strings()...), nil
+}
+
+// useEnvFile reports whether the environment variables should be
+// passed to docker in an env file.
+func (ra RunArgs) useEnvFile() bool {
+	if ra.EnvFile {
+		return true
+	}
+	return len(ra.secrets()) > 0
+}
+
+// secrets returns the values of the secret environment variables.
+func (ra RunArgs) secrets() []string {
+	var secrets []string
+	for _, ev := range ra.Env {
+		if ev.Secret && ev.Value != "" {
+			secrets = append(secrets, ev.Value)
+		}
+	}
+	return secrets
+}
+
+// command returns the command to run in the container, as an argv
+// list, or nil if the image's default command should be used.
+func (ra RunArgs) command() ([]string, error) {
+	if ra.Command != "" && len(ra.Args) > 0 {
+		return nil, fmt.Errorf("only one of Command and Args may be set")
+	}
+	if len(ra.Args) > 0 {
+		return ra.Args, nil
+	}
+	return SplitCommand(ra.Command)
+}
+
+// RemoveArgs contains the data passed to the RemoveWithArgs function.
+type RemoveArgs struct {
+	// Force indicates that a running container should be killed and
+	// removed, rather than the removal failing.
+	Force bool
+	// Volumes indicates that the anonymous volumes associated with the
+	// container should be removed too.
+	Volumes bool
+	// Link indicates that the container ID is actually the name of a
+	// link to remove, rather than a container.
+	Link bool
+	// IgnoreNotFound indicates that it is not an error if the container
+	// does not exist, so the removal may be safely retried.
+	IgnoreNotFound bool
+}
+
+// CommandlineArgs converts the RemoveArgs into a list of strings that
+// may be passed to exec.Command as the command args, ahead of the
+// container ID.
+func (ra RemoveArgs) CommandlineArgs() []string {
+	var args []string
+
+	if ra.Force {
+		args = append(args, "--force")
+	}
+
+	if ra.Volumes {
+		args = append(args, "--volumes")
+	}
+
+	if ra.Link {
+		args = append(args, "--link")
 	}
 
 	return args
 }
+
+// StopArgs contains the data passed to the StopWithArgs function.
+type StopArgs struct {
+	// Timeout is how long to wait for the container to stop before
+	// killing it. If zero, the daemon's default (10s) is used.
+	Timeout time.Duration
+	// Signal is the signal (e.g. "SIGINT") used to ask the container
+	// to stop. If empty, the container's configured stop signal
+	// (normally SIGTERM) is used.
+	Signal string
+}
+
+// CommandlineArgs converts the StopArgs into a list of strings that
+// may be passed to exec.Command as the command args, ahead of the
+// container ID.
+func (sa StopArgs) CommandlineArgs() []string {
+	var args []string
+
+	if sa.Timeout > 0 {
+		args = append(args, "--time", formatSeconds(sa.Timeout))
+	}
+
+	if sa.Signal != "" {
+		args = append(args, "--signal", sa.Signal)
+	}
+
+	return args
+}
+
+// StopResult describes how a stopped container came to exit.
+type StopResult struct {
+	// ExitCode is the exit code of the container's main process.
+	ExitCode int
+	// Killed indicates that the container did not exit within the
+	// timeout and had to be killed.
+	Killed bool
+}
+
+// newStopResult returns the StopResult for the stopped container.
+func newStopResult(info *Info) *StopResult {
+	return &StopResult{
+		ExitCode: info.State.ExitCode,
+		Killed:   info.Killed(),
+	}
+}
+
+// ListArgs contains the data passed to the List function. Containers
+// must match every filter that is set.
+type ListArgs struct {
+	// All indicates that stopped containers should be listed too,
+	// rather than only those that are running.
+	All bool
+	// Labels limits the list to containers with the labels. An empty
+	// value matches any container with the label, whatever its value.
+	Labels map[string]string
+	// Names limits the list to containers with one of the names.
+	Names []string
+	// Ancestor, if set, limits the list to containers created from the
+	// image (or one derived from it).
+	Ancestor string
+	// Status, if set, limits the list to containers in that state
+	// (e.g. "running", "exited").
+	Status string
+}
+
+// CommandlineArgs converts the ListArgs into a list of strings that
+// may be passed to exec.Command as the command args.
+func (la ListArgs) CommandlineArgs() []string {
+	var args []string
+
+	if la.All {
+		args = append(args, "--all")
+	}
+
+	for _, filter := range la.filters() {
+		args = append(args, "--filter", filter[0]+"="+filter[1])
+	}
+
+	return args
+}
+
+// filters returns the filters to apply, as name/value pairs, in
+// a consistent order.
+func (la ListArgs) filters() [][2]string {
+	var filters [][2]string
+
+	keys := make([]string, 0, len(la.Labels))
+	for key := range la.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		label := key
+		if value := la.Labels[key]; value != "" {
+			label += "=" + value
+		}
+		filters = append(filters, [2]string{"label", label})
+	}
+
+	for _, name := range la.Names {
+		filters = append(filters, [2]string{"name", name})
+	}
+
+	if la.Ancestor != "" {
+		filters = append(filters, [2]string{"ancestor", la.Ancestor})
+	}
+
+	if la.Status != "" {
+		filters = append(filters, [2]string{"status", la.Status})
+	}
+
+	return filters
+}
+
+// InspectResult holds the outcome of inspecting one of the containers
+// passed to the InspectMany function.
+type InspectResult struct {
+	// ID is the container ID (or name), as requested.
+	ID string
+	// Info is the information about the container, if it was found.
+	Info *Info
+	// Err is the reason the container could not be inspected
+	// (e.g. ErrNotFound).
+	Err error
+}
+
+// LogsArgs contains the data passed to the Logs function.
+type LogsArgs struct {
+	// Tail is the number of lines to return from the end of the logs.
+	// If zero, all lines are returned.
+	Tail int
+	// Since, if set, limits the logs to those written at or after
+	// that time.
+	Since time.Time
+	// Until, if set, limits the logs to those written before that time.
+	Until time.Time
+	// Timestamps indicates that each line should be prefixed with the
+	// time it was written.
+	Timestamps bool
+	// Follow indicates that the logs should continue to be streamed as
+	// the container writes them, until the reader is closed.
+	Follow bool
+}
+
+// CommandlineArgs converts the LogsArgs into a list of strings that
+// may be passed to exec.Command as the command args, ahead of the
+// container ID.
+func (la LogsArgs) CommandlineArgs() []string {
+	var args []string
+
+	if la.Tail > 0 {
+		args = append(args, "--tail", strconv.Itoa(la.Tail))
+	}
+
+	if !la.Since.IsZero() {
+		args = append(args, "--since", la.Since.UTC().Format(time.RFC3339Nano))
+	}
+
+	if !la.Until.IsZero() {
+		args = append(args, "--until", la.Until.UTC().Format(time.RFC3339Nano))
+	}
+
+	if la.Timestamps {
+		args = append(args, "--timestamps")
+	}
+
+	if la.Follow {
+		args = append(args, "--follow")
+	}
+
+	return args
+}
+
+// ExecArgs contains the data passed to the Exec function.
+type ExecArgs struct {
+	// Cmd is the command to run in the container, as an argv list.
+	Cmd []string
+	// Env holds the additional environment variables to set for the
+	// command, if any.
+	Env map[string]string
+	// User is the user (name or UID, optionally with a group) to run
+	// the command as (optional).
+	User string
+	// WorkDir is the working directory to run the command in
+	// (optional).
+	WorkDir string
+	// Stdin, if set, is copied to the command's standard input.
+	Stdin io.Reader
+	// TTY indicates that the command should be attached to a
+	// pseudo-terminal, in which case its stdout and stderr are merged.
+	// The docker CLI can only attach a terminal as the command's input,
+	// so CLIClient does not allow TTY together with Stdin.
+	TTY bool
+}
+
+// CommandlineArgs converts the ExecArgs into a list of strings that
+// may be passed to exec.Command as the command args, with the command
+// following the container ID.
+func (ea ExecArgs) CommandlineArgs(id string) []string {
+	var args []string
+
+	if ea.Stdin != nil {
+		args = append(args, "--interactive")
+	}
+
+	if ea.TTY {
+		args = append(args, "--tty")
+	}
+
+	for _, env := range sortedEnv(ea.Env) {
+		args = append(args, "--env", env)
+	}
+
+	if ea.User != "" {
+		args = append(args, "--user", ea.User)
+	}
+
+	if ea.WorkDir != "" {
+		args = append(args, "--workdir", ea.WorkDir)
+	}
+
+	args = append(args, id)
+	return append(args, ea.Cmd...)
+}
+
+// ExecResult holds the outcome of a command run with Exec.
+type ExecResult struct {
+	// Stdout is what the command wrote to its standard output.
+	Stdout []byte
+	// Stderr is what the command wrote to its standard error.
+	Stderr []byte
+	// ExitCode is the exit code of the command.
+	ExitCode int
+}
+
+// sortedEnv converts the environment variables into a list of
+// "KEY=value" strings, ordered by key.
+func sortedEnv(vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var env []string
+	for _, k := range keys {
+		env = append(env, k+"="+vars[k])
+	}
+	return env
+}
@@ -4,7 +4,13 @@
 package docker_test
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
@@ -28,6 +34,7 @@ func newClient(outs ...string) (*docker.CLIClient, *fakeRunDocker) {
 	}
 	client := docker.NewCLIClient()
 	client.RunDocker = fake.exec
+	client.StreamDocker = fake.stream
 	return client, fake
 }
 
@@ -75,6 +82,358 @@ func (dockerSuite) TestRunMinimal(c *gc.C) {
 	})
 }
 
+func (dockerSuite) TestRunEnvOrdering(c *gc.C) {
+	args := docker.RunArgs{
+		Image: "my-spam",
+		EnvVars: map[string]string{
+			"FOO":  "bar",
+			"BAZ":  "qux",
+			"SPAM": "eggs",
+			"A":    "1",
+			"Z":    "26",
+		},
+		Env: docker.EnvList{
+			{Name: "PATH", Value: "/usr/bin"},
+			{Name: "FOO", Value: "override"},
+			{Name: "PATH", Value: "/opt/spam/bin:/usr/bin"},
+		},
+	}
+	expected := []string{
+		"--detach",
+		"-e", "A=1",
+		"-e", "BAZ=qux",
+		"-e", "FOO=bar",
+		"-e", "SPAM=eggs",
+		"-e", "Z=26",
+		"-e", "PATH=/usr/bin",
+		"-e", "FOO=override",
+		"-e", "PATH=/opt/spam/bin:/usr/bin",
+		"my-spam",
+	}
+
+	// Map iteration order varies, so check that it doesn't leak
+	// into the args.
+	for i := 0; i < 10; i++ {
+		c.Check(args.CommandlineArgs(), jc.DeepEquals, expected)
+	}
+}
+
+func (dockerSuite) TestCommandlineArgsInvalid(c *gc.C) {
+	args := docker.RunArgs{
+		Image:   "my-spam",
+		Command: `sh -c "echo`,
+	}
+
+	c.Check(args.CommandlineArgs(), gc.IsNil)
+
+	_, err := args.ValidCommandlineArgs()
+	c.Check(err, gc.ErrorMatches, `invalid run args: Command: invalid command .*: unterminated double quote`)
+}
+
+func (dockerSuite) TestRunBadEnv(c *gc.C) {
+	client, fake := newClient("eggs")
+
+	_, err := client.Run(docker.RunArgs{
+		Image: "my-spam",
+		Env:   docker.EnvList{{Name: "FOO=BAR", Value: "baz"}},
+	})
+	c.Check(err, gc.ErrorMatches, `invalid run args: Env\[0\]: invalid env var name "FOO=BAR": must not contain "="`)
+
+	_, err = client.Run(docker.RunArgs{
+		Image:   "my-spam",
+		EnvVars: map[string]string{"": "baz"},
+	})
+	c.Check(err, gc.ErrorMatches, `invalid run args: EnvVars\[""\]: invalid env var name "": must not be empty`)
+	c.Check(fake.index, gc.Equals, 0)
+}
+
+func (dockerSuite) TestRunEnvFile(c *gc.C) {
+	client, fake := newClient("eggs")
+	var envFile string
+	var envData []byte
+	var envMode os.FileMode
+	client.RunDocker = func(ctx context.Context, command string, args ...string) ([]byte, error) {
+		for i, arg := range args {
+			if arg == "--env-file" {
+				envFile = args[i+1]
+			}
+		}
+		fi, err := os.Stat(envFile)
+		c.Assert(err, jc.ErrorIsNil)
+		envMode = fi.Mode()
+		envData, err = ioutil.ReadFile(envFile)
+		c.Assert(err, jc.ErrorIsNil)
+		return fake.exec(ctx, command, args...)
+	}
+
+	id, err := client.Run(docker.RunArgs{
+		Image:   "my-spam",
+		EnvVars: map[string]string{"FOO": "bar", "BAZ": "qux"},
+		Env:     docker.EnvList{{Name: "FOO", Value: "override"}},
+		EnvFile: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(id, gc.Equals, "eggs")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--detach",
+		"--env-file", envFile,
+		"my-spam",
+	})
+	c.Check(string(envData), gc.Equals, "BAZ=qux\nFOO=bar\nFOO=override\n")
+	c.Check(envMode.Perm(), gc.Equals, os.FileMode(0600))
+	_, err = os.Stat(envFile)
+	c.Check(os.IsNotExist(err), jc.IsTrue)
+}
+
+func (dockerSuite) TestRunSecretEnv(c *gc.C) {
+	client, fake := newClient()
+	fake.calls = []runDockerCall{{
+		err: `Error response from daemon: Conflict. The name "hunter2" is already in use by container b508c7d5c272.`,
+	}}
+
+	_, err := client.Run(docker.RunArgs{
+		Image:   "my-spam",
+		EnvVars: map[string]string{"FOO": "bar"},
+		Env:     docker.EnvList{{Name: "PASSWORD", Value: "hunter2", Secret: true}},
+	})
+
+	c.Check(err, gc.ErrorMatches, `exit status 1: Error response from daemon: Conflict. The name "<redacted>" is already in use by container b508c7d5c272.`)
+	c.Check(docker.IsConflict(err), jc.IsTrue)
+	args := fake.calls[0].argsIn
+	c.Assert(args, gc.HasLen, 4)
+	c.Check(args[1], gc.Equals, "--env-file")
+	for _, arg := range args {
+		c.Check(arg, gc.Not(jc.Contains), "hunter2")
+	}
+}
+
+func (dockerSuite) TestRunEnvFileNewline(c *gc.C) {
+	client, fake := newClient("eggs")
+
+	_, err := client.Run(docker.RunArgs{
+		Image: "my-spam",
+		Env:   docker.EnvList{{Name: "KEY", Value: "line 1\nline 2", Secret: true}},
+	})
+
+	c.Check(err, gc.ErrorMatches, `invalid run args: Env\[0\]: value contains a newline, which can't be written to an env file`)
+	c.Check(fake.index, gc.Equals, 0)
+}
+
+func (dockerSuite) TestEnvVarString(c *gc.C) {
+	env := docker.EnvList{
+		{Name: "FOO", Value: "bar"},
+		{Name: "PASSWORD", Value: "hunter2", Secret: true},
+	}
+
+	c.Check(fmt.Sprint(env), gc.Equals, "[FOO=bar PASSWORD=<redacted>]")
+	c.Check(fmt.Sprintf("%+v", docker.RunArgs{Env: env}), gc.Not(jc.Contains), "hunter2")
+	c.Check(env.Strings(), jc.DeepEquals, []string{"FOO=bar", "PASSWORD=hunter2"})
+}
+
+func (dockerSuite) TestRunPortBindings(c *gc.C) {
+	client, fake := newClient("eggs")
+
+	_, err := client.Run(docker.RunArgs{
+		Image: "my-spam",
+		Ports: []docker.PortAssignment{
+			{HostIP: "127.0.0.1", External: 8080, Internal: 80},
+			{HostIP: "::1", Internal: 443, Protocol: "tcp"},
+			{External: 7000, Internal: 7000, Count: 11, Protocol: "udp"},
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--detach",
+		"-p", "127.0.0.1:8080:80",
+		"-p", "[::1]::443/tcp",
+		"-p", "7000-7010:7000-7010/udp",
+		"my-spam",
+	})
+}
+
+func (dockerSuite) TestRunQuotedCommand(c *gc.C) {
+	client, fake := newClient("eggs")
+
+	args := docker.RunArgs{
+		Image:   "my-spam",
+		Command: `sh -c "echo hello world"`,
+	}
+	_, err := client.Run(args)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--detach",
+		"my-spam",
+		"sh", "-c", "echo hello world",
+	})
+}
+
+func (dockerSuite) TestRunArgs(c *gc.C) {
+	client, fake := newClient("eggs")
+
+	args := docker.RunArgs{
+		Image: "my-spam",
+		Args:  []string{"sh", "-c", `echo "hello world"`},
+	}
+	_, err := client.Run(args)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--detach",
+		"my-spam",
+		"sh", "-c", `echo "hello world"`,
+	})
+}
+
+func (dockerSuite) TestRunEntrypoint(c *gc.C) {
+	client, fake := newClient("eggs")
+
+	args := docker.RunArgs{
+		Name:       "spam",
+		Image:      "my-spam",
+		Command:    "do something",
+		Entrypoint: []string{"/bin/sh", "-c"},
+		WorkDir:    "/var/lib/spam",
+		User:       "1000:1000",
+	}
+	_, err := client.Run(args)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--detach",
+		"--name", "spam",
+		"--entrypoint", "/bin/sh",
+		"--workdir", "/var/lib/spam",
+		"--user", "1000:1000",
+		"my-spam",
+		"-c", "do", "something",
+	})
+}
+
+func (dockerSuite) TestRunResources(c *gc.C) {
+	client, fake := newClient("eggs")
+
+	args := docker.RunArgs{
+		Image: "my-spam",
+		Resources: docker.Resources{
+			Memory: 512 * docker.MiB,
+			CPUs:   1.5,
+		},
+	}
+	_, err := client.Run(args)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--detach",
+		"--memory", "512m",
+		"--cpus", "1.5",
+		"my-spam",
+	})
+}
+
+func (dockerSuite) TestRunBadResources(c *gc.C) {
+	client, fake := newClient("eggs")
+
+	args := docker.RunArgs{
+		Image: "my-spam",
+		Resources: docker.Resources{
+			BlkioWeight: 1,
+		},
+	}
+	_, err := client.Run(args)
+
+	c.Check(err, gc.ErrorMatches, "invalid run args: Resources.BlkioWeight: invalid blkio weight 1: .*")
+	c.Check(fake.index, gc.Equals, 0)
+}
+
+func (dockerSuite) TestRunRestartPolicy(c *gc.C) {
+	client, fake := newClient("eggs")
+
+	args := docker.RunArgs{
+		Image: "my-spam",
+		RestartPolicy: docker.RestartPolicy{
+			Name:       docker.RestartOnFailure,
+			MaxRetries: 3,
+		},
+	}
+	_, err := client.Run(args)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--detach",
+		"--restart", "on-failure:3",
+		"my-spam",
+	})
+}
+
+func (dockerSuite) TestRunBadRestartPolicy(c *gc.C) {
+	client, fake := newClient("eggs")
+
+	args := docker.RunArgs{
+		Image: "my-spam",
+		RestartPolicy: docker.RestartPolicy{
+			Name:       docker.RestartAlways,
+			MaxRetries: 3,
+		},
+	}
+	_, err := client.Run(args)
+
+	c.Check(err, gc.ErrorMatches, `invalid run args: RestartPolicy.MaxRetries: invalid restart policy "always:3": .*`)
+	c.Check(fake.index, gc.Equals, 0)
+}
+
+func (dockerSuite) TestRunLabels(c *gc.C) {
+	client, fake := newClient("eggs")
+
+	args := docker.RunArgs{
+		Image: "my-spam",
+		Labels: map[string]string{
+			docker.LabelUnit:      "spam/0",
+			docker.LabelProcess:   "eggs",
+			docker.LabelModelUUID: "deadbeef-0bad-400d-8000-4b1d0d06f00d",
+		},
+	}
+	_, err := client.Run(args)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--detach",
+		"--label", "com.canonical.juju.model-uuid=deadbeef-0bad-400d-8000-4b1d0d06f00d",
+		"--label", "com.canonical.juju.process=eggs",
+		"--label", "com.canonical.juju.unit=spam/0",
+		"my-spam",
+	})
+}
+
+func (dockerSuite) TestRunBadCommand(c *gc.C) {
+	client, fake := newClient("eggs")
+
+	args := docker.RunArgs{
+		Image:   "my-spam",
+		Command: `sh -c "echo hello world`,
+	}
+	_, err := client.Run(args)
+
+	c.Check(err, gc.ErrorMatches, `invalid run args: Command: invalid command .*: unterminated double quote`)
+	c.Check(fake.index, gc.Equals, 0)
+}
+
+func (dockerSuite) TestRunCommandAndArgs(c *gc.C) {
+	client, fake := newClient("eggs")
+
+	args := docker.RunArgs{
+		Image:   "my-spam",
+		Command: "do something",
+		Args:    []string{"do", "something"},
+	}
+	_, err := client.Run(args)
+
+	c.Check(err, gc.ErrorMatches, "invalid run args: Command: only one of Command and Args may be set")
+	c.Check(fake.index, gc.Equals, 0)
+}
+
 func (dockerSuite) TestInspectOkay(c *gc.C) {
 	client, fake := newClient(fakeInspectOutput)
 
@@ -89,6 +448,74 @@ func (dockerSuite) TestInspectOkay(c *gc.C) {
 	})
 }
 
+func (dockerSuite) TestInspectManyOkay(c *gc.C) {
+	client, fake := newClient("[" + inspectObject(fakeInspectOutput) + "," + inspectObject(exitedInspectOutput(1)) + "]")
+
+	results, err := client.InspectMany("sad_perlman", "b508c7d5c272")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(results, gc.HasLen, 2)
+	c.Check(results[0].ID, gc.Equals, "sad_perlman")
+	c.Check(results[0].Info, jc.DeepEquals, (*docker.Info)(fakeInfo))
+	c.Check(results[0].Err, jc.ErrorIsNil)
+	c.Check(results[1].ID, gc.Equals, "b508c7d5c272")
+	c.Check(results[1].Info.State.ExitCode, gc.Equals, 1)
+	c.Check(results[1].Err, jc.ErrorIsNil)
+	c.Check(fake.index, gc.Equals, 1)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "inspect")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"sad_perlman",
+		"b508c7d5c272",
+	})
+}
+
+func (dockerSuite) TestInspectManyMissing(c *gc.C) {
+	client, fake := newClient()
+	missing := "Error: No such object: spam\nError: No such object: eggs"
+	fake.calls = []runDockerCall{{
+		out:    []byte(fakeInspectOutput),
+		err:    missing,
+		errOut: []byte(missing + "\n"),
+	}}
+
+	results, err := client.InspectMany("spam", "sad_perlman", "eggs")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(results, gc.HasLen, 3)
+	c.Check(results[0].ID, gc.Equals, "spam")
+	c.Check(results[0].Info, gc.IsNil)
+	c.Check(results[0].Err, gc.ErrorMatches, "Error: No such object: spam")
+	c.Check(docker.IsNotFound(results[0].Err), jc.IsTrue)
+	c.Check(results[1].ID, gc.Equals, "sad_perlman")
+	c.Check(results[1].Info, jc.DeepEquals, (*docker.Info)(fakeInfo))
+	c.Check(results[1].Err, jc.ErrorIsNil)
+	c.Check(results[2].ID, gc.Equals, "eggs")
+	c.Check(docker.IsNotFound(results[2].Err), jc.IsTrue)
+}
+
+func (dockerSuite) TestInspectManyFailed(c *gc.C) {
+	client, fake := newClient()
+	fake.calls = []runDockerCall{{
+		out:    []byte("[]"),
+		err:    "Cannot connect to the Docker daemon. Is the docker daemon running on this host?",
+		errOut: []byte("Cannot connect to the Docker daemon. Is the docker daemon running on this host?"),
+	}}
+
+	_, err := client.InspectMany("sad_perlman", "spam")
+
+	c.Check(docker.IsDaemonUnavailable(err), jc.IsTrue)
+}
+
+func (dockerSuite) TestInspectManyNone(c *gc.C) {
+	client, fake := newClient()
+
+	results, err := client.InspectMany()
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(results, gc.HasLen, 0)
+	c.Check(fake.index, gc.Equals, 0)
+}
+
 func (dockerSuite) TestStopOkay(c *gc.C) {
 	client, fake := newClient("")
 
@@ -115,13 +542,582 @@ func (dockerSuite) TestRemoveOkay(c *gc.C) {
 	})
 }
 
+// exitedInspectOutput returns the docker inspect output for the
+// container in fakeInspectOutput, once it has exited with the code.
+func exitedInspectOutput(exitCode int) string {
+	return strings.NewReplacer(
+		`"Running": true`, `"Running": false`,
+		`"ExitCode": 0`, fmt.Sprintf(`"ExitCode": %d`, exitCode),
+	).Replace(fakeInspectOutput)
+}
+
+func (dockerSuite) TestStopWithArgsExited(c *gc.C) {
+	client, fake := newClient("", exitedInspectOutput(0))
+
+	result, err := client.StopWithArgs("sad_perlman", docker.StopArgs{
+		Timeout: 2 * time.Minute,
+		Signal:  "SIGINT",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(result, jc.DeepEquals, &docker.StopResult{
+		ExitCode: 0,
+		Killed:   false,
+	})
+	c.Check(fake.index, gc.Equals, 2)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "stop")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--time", "120",
+		"--signal", "SIGINT",
+		"sad_perlman",
+	})
+	c.Check(fake.calls[1].commandIn, gc.Equals, "inspect")
+	c.Check(fake.calls[1].argsIn, jc.DeepEquals, []string{
+		"sad_perlman",
+	})
+}
+
+func (dockerSuite) TestStopWithArgsKilled(c *gc.C) {
+	client, fake := newClient("", exitedInspectOutput(137))
+
+	result, err := client.StopWithArgs("sad_perlman", docker.StopArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(result, jc.DeepEquals, &docker.StopResult{
+		ExitCode: 137,
+		Killed:   true,
+	})
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"sad_perlman",
+	})
+}
+
+func (dockerSuite) TestRemoveWithArgs(c *gc.C) {
+	client, fake := newClient("")
+
+	err := client.RemoveWithArgs("sad_perlman", docker.RemoveArgs{
+		Force:   true,
+		Volumes: true,
+		Link:    true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.index, gc.Equals, 1)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "rm")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--force",
+		"--volumes",
+		"--link",
+		"sad_perlman",
+	})
+}
+
+func (dockerSuite) TestRemoveWithArgsNotFound(c *gc.C) {
+	client, fake := newClient()
+	fake.calls = []runDockerCall{{err: "Error response from daemon: No such container: sad_perlman"}}
+
+	err := client.RemoveWithArgs("sad_perlman", docker.RemoveArgs{})
+
+	c.Check(docker.IsNotFound(err), jc.IsTrue)
+}
+
+func (dockerSuite) TestRemoveWithArgsIgnoreNotFound(c *gc.C) {
+	client, fake := newClient()
+	fake.calls = []runDockerCall{{err: "Error response from daemon: No such container: sad_perlman"}}
+
+	err := client.RemoveWithArgs("sad_perlman", docker.RemoveArgs{
+		Force:          true,
+		IgnoreNotFound: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--force",
+		"sad_perlman",
+	})
+}
+
+func (dockerSuite) TestRemoveWithArgsIgnoreNotFoundOtherError(c *gc.C) {
+	client, fake := newClient()
+	fake.calls = []runDockerCall{{err: "Cannot connect to the Docker daemon. Is the docker daemon running on this host?"}}
+
+	err := client.RemoveWithArgs("sad_perlman", docker.RemoveArgs{
+		IgnoreNotFound: true,
+	})
+
+	c.Check(docker.IsDaemonUnavailable(err), jc.IsTrue)
+}
+
+func (dockerSuite) TestStartOkay(c *gc.C) {
+	client, fake := newClient("")
+
+	err := client.Start("sad_perlman")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.index, gc.Equals, 1)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "start")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"sad_perlman",
+	})
+}
+
+func (dockerSuite) TestRestartOkay(c *gc.C) {
+	client, fake := newClient("")
+
+	err := client.Restart("sad_perlman", 30*time.Second)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.index, gc.Equals, 1)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "restart")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--time", "30",
+		"sad_perlman",
+	})
+}
+
+func (dockerSuite) TestKillOkay(c *gc.C) {
+	client, fake := newClient("")
+
+	err := client.Kill("sad_perlman", "SIGINT")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.index, gc.Equals, 1)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "kill")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--signal", "SIGINT",
+		"sad_perlman",
+	})
+}
+
+func (dockerSuite) TestKillDefaultSignal(c *gc.C) {
+	client, fake := newClient("")
+
+	err := client.Kill("sad_perlman", "")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.index, gc.Equals, 1)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "kill")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"sad_perlman",
+	})
+}
+
+func (dockerSuite) TestPauseOkay(c *gc.C) {
+	client, fake := newClient("")
+
+	err := client.Pause("sad_perlman")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.index, gc.Equals, 1)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "pause")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"sad_perlman",
+	})
+}
+
+func (dockerSuite) TestUnpauseOkay(c *gc.C) {
+	client, fake := newClient("")
+
+	err := client.Unpause("sad_perlman")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.index, gc.Equals, 1)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "unpause")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"sad_perlman",
+	})
+}
+
+func (dockerSuite) TestRunContext(c *gc.C) {
+	client, fake := newClient("eggs")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	id, err := client.RunContext(ctx, docker.RunArgs{Image: "my-spam"})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(id, gc.Equals, "eggs")
+	c.Check(fake.calls[0].ctxIn, gc.Equals, ctx)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "run")
+}
+
+func (dockerSuite) TestInspectContext(c *gc.C) {
+	client, fake := newClient(fakeInspectOutput)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := client.InspectContext(ctx, "sad_perlman")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.calls[0].ctxIn, gc.Equals, ctx)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "inspect")
+}
+
+func (dockerSuite) TestStopContext(c *gc.C) {
+	client, fake := newClient("")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := client.StopContext(ctx, "sad_perlman")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.calls[0].ctxIn, gc.Equals, ctx)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "stop")
+}
+
+func (dockerSuite) TestRemoveContext(c *gc.C) {
+	client, fake := newClient("")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := client.RemoveContext(ctx, "sad_perlman")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.calls[0].ctxIn, gc.Equals, ctx)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "rm")
+}
+
+func (dockerSuite) TestLogsOkay(c *gc.C) {
+	client, fake := newClient("hello\nworld\n")
+
+	since := time.Date(2015, 6, 25, 11, 5, 53, 0, time.UTC)
+	r, err := client.Logs("sad_perlman", docker.LogsArgs{
+		Tail:       10,
+		Since:      since,
+		Until:      since.Add(time.Hour),
+		Timestamps: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	data, err := ioutil.ReadAll(r)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(r.Close(), jc.ErrorIsNil)
+
+	c.Check(string(data), gc.Equals, "hello\nworld\n")
+	c.Check(fake.index, gc.Equals, 1)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "logs")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--tail", "10",
+		"--since", "2015-06-25T11:05:53Z",
+		"--until", "2015-06-25T12:05:53Z",
+		"--timestamps",
+		"sad_perlman",
+	})
+}
+
+func (dockerSuite) TestLogsNotFound(c *gc.C) {
+	client, fake := newClient()
+	fake.calls = []runDockerCall{{err: "Error: No such container: sad_perlman"}}
+
+	r, err := client.Logs("sad_perlman", docker.LogsArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	defer r.Close()
+	_, err = ioutil.ReadAll(r)
+
+	c.Check(docker.IsNotFound(err), jc.IsTrue)
+}
+
+func (dockerSuite) TestLogsFollowClose(c *gc.C) {
+	client, fake := newClient()
+	fake.calls = []runDockerCall{{out: []byte("hello\n"), hang: true}}
+
+	r, err := client.Logs("sad_perlman", docker.LogsArgs{Follow: true})
+	c.Assert(err, jc.ErrorIsNil)
+	buf := make([]byte, 6)
+	_, err = io.ReadFull(r, buf)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(buf), gc.Equals, "hello\n")
+
+	c.Assert(r.Close(), jc.ErrorIsNil)
+	select {
+	case <-fake.calls[0].ctxIn.Done():
+	case <-time.After(time.Second):
+		c.Fatalf("docker logs not stopped")
+	}
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--follow",
+		"sad_perlman",
+	})
+}
+
+func (dockerSuite) TestExecOkay(c *gc.C) {
+	client, fake := newClient()
+	fake.calls = []runDockerCall{{
+		out:    []byte("PONG\n"),
+		errOut: []byte("warning\n"),
+	}}
+
+	result, err := client.Exec("sad_perlman", docker.ExecArgs{
+		Cmd: []string{"redis-cli", "-a", "secret word", "ping"},
+		Env: map[string]string{
+			"FOO": "bar",
+			"BAZ": "qux",
+		},
+		User:    "1000:1000",
+		WorkDir: "/data",
+		Stdin:   strings.NewReader("input"),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(result, jc.DeepEquals, &docker.ExecResult{
+		Stdout:   []byte("PONG\n"),
+		Stderr:   []byte("warning\n"),
+		ExitCode: 0,
+	})
+	c.Check(fake.index, gc.Equals, 1)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "exec")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--interactive",
+		"--env", "BAZ=qux",
+		"--env", "FOO=bar",
+		"--user", "1000:1000",
+		"--workdir", "/data",
+		"sad_perlman",
+		"redis-cli", "-a", "secret word", "ping",
+	})
+	c.Check(string(fake.calls[0].stdinIn), gc.Equals, "input")
+}
+
+func (dockerSuite) TestExecTTY(c *gc.C) {
+	client, fake := newClient("PONG\r\n")
+
+	result, err := client.Exec("sad_perlman", docker.ExecArgs{
+		Cmd: []string{"redis-cli", "ping"},
+		TTY: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(result.Stdout, jc.DeepEquals, []byte("PONG\r\n"))
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--tty",
+		"sad_perlman",
+		"redis-cli", "ping",
+	})
+}
+
+func (dockerSuite) TestExecTTYWithStdin(c *gc.C) {
+	client, fake := newClient()
+
+	_, err := client.Exec("sad_perlman", docker.ExecArgs{
+		Cmd:   []string{"sh"},
+		Stdin: strings.NewReader("input"),
+		TTY:   true,
+	})
+
+	c.Check(err, gc.ErrorMatches, "can't exec in container sad_perlman with both a TTY and stdin")
+	c.Check(fake.index, gc.Equals, 0)
+}
+
+func (dockerSuite) TestExecExitCode(c *gc.C) {
+	client, fake := newClient()
+	fake.calls = []runDockerCall{{
+		out:      []byte("partial\n"),
+		errOut:   []byte("bad things\n"),
+		exitcode: 3,
+	}}
+
+	result, err := client.Exec("sad_perlman", docker.ExecArgs{
+		Cmd: []string{"check-health"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(result, jc.DeepEquals, &docker.ExecResult{
+		Stdout:   []byte("partial\n"),
+		Stderr:   []byte("bad things\n"),
+		ExitCode: 3,
+	})
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"sad_perlman",
+		"check-health",
+	})
+}
+
+func (dockerSuite) TestExecNotRunning(c *gc.C) {
+	client, fake := newClient()
+	fake.calls = []runDockerCall{{
+		err:    "Error response from daemon: Container sad_perlman is not running",
+		errOut: []byte("Error response from daemon: Container sad_perlman is not running\n"),
+	}}
+
+	_, err := client.Exec("sad_perlman", docker.ExecArgs{
+		Cmd: []string{"check-health"},
+	})
+
+	c.Check(docker.IsConflict(err), jc.IsTrue)
+}
+
+func (dockerSuite) TestExecDaemonUnavailable(c *gc.C) {
+	client, fake := newClient()
+	fake.calls = []runDockerCall{{
+		err:    "Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?",
+		errOut: []byte("Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?\n"),
+	}}
+
+	_, err := client.Exec("sad_perlman", docker.ExecArgs{
+		Cmd: []string{"check-health"},
+	})
+
+	c.Check(docker.IsDaemonUnavailable(err), jc.IsTrue)
+}
+
+func (dockerSuite) TestExecCommandFailedLikeDocker(c *gc.C) {
+	// The command's stderr is folded into the error, as it is for any
+	// streamed docker command, but it is the command's own failure.
+	for i, msg := range []string{
+		"permission denied",
+		"bind: address already in use",
+		"nginx is not running",
+		"Conflict. The name is already in use by container spam",
+	} {
+		c.Logf("test %d: %s", i, msg)
+		client, fake := newClient()
+		fake.calls = []runDockerCall{{
+			err:      msg,
+			errOut:   []byte(msg + "\n"),
+			exitcode: 1,
+		}}
+
+		result, err := client.Exec("sad_perlman", docker.ExecArgs{
+			Cmd: []string{"check-health"},
+		})
+		c.Assert(err, jc.ErrorIsNil)
+
+		c.Check(result, jc.DeepEquals, &docker.ExecResult{
+			Stderr:   []byte(msg + "\n"),
+			ExitCode: 1,
+		})
+	}
+}
+
+func (dockerSuite) TestWaitOkay(c *gc.C) {
+	exited := strings.Replace(exitedInspectOutput(2),
+		`"FinishedAt": "0001-01-01T00:00:00Z"`,
+		`"FinishedAt": "2015-06-25T11:06:23.9123Z"`, 1)
+	client, fake := newClient("2\n", exited)
+
+	status, err := client.Wait(context.Background(), "sad_perlman")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(status, jc.DeepEquals, &docker.ExitStatus{
+		ExitCode:   2,
+		OOMKilled:  false,
+		FinishedAt: time.Date(2015, 6, 25, 11, 6, 23, 912300000, time.UTC),
+	})
+	c.Check(fake.index, gc.Equals, 2)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "wait")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"sad_perlman",
+	})
+	c.Check(fake.calls[1].commandIn, gc.Equals, "inspect")
+}
+
+func (dockerSuite) TestWaitOOMKilled(c *gc.C) {
+	exited := strings.Replace(exitedInspectOutput(137), `"OOMKilled": false`, `"OOMKilled": true`, 1)
+	client, _ := newClient("137\n", exited)
+
+	status, err := client.Wait(context.Background(), "sad_perlman")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(status, jc.DeepEquals, &docker.ExitStatus{
+		ExitCode:  137,
+		OOMKilled: true,
+	})
+}
+
+func (dockerSuite) TestWaitRemoved(c *gc.C) {
+	client, fake := newClient("0\n")
+	fake.calls = append(fake.calls, runDockerCall{
+		err: "Error: No such object: sad_perlman",
+	})
+
+	status, err := client.Wait(context.Background(), "sad_perlman")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(status, jc.DeepEquals, &docker.ExitStatus{
+		ExitCode: 0,
+	})
+}
+
+func (dockerSuite) TestWaitBadOutput(c *gc.C) {
+	client, _ := newClient("spam\n")
+
+	_, err := client.Wait(context.Background(), "sad_perlman")
+
+	c.Check(err, gc.ErrorMatches, `can't decode response from docker wait sad_perlman: .*`)
+}
+
+func (dockerSuite) TestListOkay(c *gc.C) {
+	client, fake := newClient(fakePSOutput)
+
+	summaries, err := client.List(docker.ListArgs{
+		All: true,
+		Labels: map[string]string{
+			"com.canonical.juju.unit": "spam/0",
+			"flavour":                 "",
+		},
+		Names:    []string{"sad_perlman", "eggs"},
+		Ancestor: "docker/whalesay",
+		Status:   "running",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(summaries, gc.HasLen, 2)
+	c.Check(fake.index, gc.Equals, 1)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "ps")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--no-trunc",
+		"--format", "{{json .}}",
+		"--all",
+		"--filter", "label=com.canonical.juju.unit=spam/0",
+		"--filter", "label=flavour",
+		"--filter", "name=sad_perlman",
+		"--filter", "name=eggs",
+		"--filter", "ancestor=docker/whalesay",
+		"--filter", "status=running",
+	})
+}
+
+func (dockerSuite) TestListNone(c *gc.C) {
+	client, fake := newClient("")
+
+	summaries, err := client.List(docker.ListArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(summaries, gc.HasLen, 0)
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--no-trunc",
+		"--format", "{{json .}}",
+	})
+}
+
 type runDockerCall struct {
 	out      []byte
 	err      string
 	exitcode int
+	// errOut is what a streamed command writes to stderr.
+	errOut []byte
+	// hang indicates that a streamed command should keep running
+	// once its output is written, until it is killed.
+	hang bool
 
+	ctxIn     context.Context
 	commandIn string
 	argsIn    []string
+	stdinIn   []byte
+}
+
+// fakeExitError is the error for a docker command that exited with a
+// non-zero exit code.
+type fakeExitError struct {
+	code int
+	err  error
+}
+
+func (e *fakeExitError) Error() string {
+	return fmt.Sprintf("exit status %d: %v", e.code, e.err)
+}
+
+func (e *fakeExitError) ExitCode() int {
+	return e.code
 }
 
 type fakeRunDocker struct {
@@ -138,7 +1134,8 @@ func (fakeRunDocker) checkArgs(command string, args []string) error {
 	return nil
 }
 
-func (frd *fakeRunDocker) exec(command string, args ...string) (_ []byte, rErr error) {
+func (frd *fakeRunDocker) exec(ctx context.Context, command string, args ...string) (_ []byte, rErr error) {
+	frd.calls[frd.index].ctxIn = ctx
 	frd.calls[frd.index].commandIn = command
 	frd.calls[frd.index].argsIn = args
 	call := frd.calls[frd.index]
@@ -153,7 +1150,7 @@ func (frd *fakeRunDocker) exec(command string, args ...string) (_ []byte, rErr e
 			if exitcode == 0 {
 				exitcode = 1
 			}
-			rErr = fmt.Errorf("exit status %d: %v", exitcode, rErr)
+			rErr = &fakeExitError{code: exitcode, err: rErr}
 		}
 	}()
 
@@ -167,3 +1164,22 @@ func (frd *fakeRunDocker) exec(command string, args ...string) (_ []byte, rErr e
 	}
 	return call.out, rErr
 }
+
+func (frd *fakeRunDocker) stream(ctx context.Context, streams docker.Streams, command string, args ...string) error {
+	call := &frd.calls[frd.index]
+	if streams.Stdin != nil {
+		call.stdinIn, _ = ioutil.ReadAll(streams.Stdin)
+	}
+	_, err := frd.exec(ctx, command, args...)
+	if streams.Stdout != nil {
+		streams.Stdout.Write(call.out)
+	}
+	if streams.Stderr != nil {
+		streams.Stderr.Write(call.errOut)
+	}
+	if err == nil && call.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// writeEnvFile writes the environment variables to a new file that
+// only the current user may read, in the form docker run --env-file
+// expects. The values must not contain newlines (see RunArgs.Validate).
+// The caller must remove the file once it is finished with.
+func writeEnvFile(env []string) (string, error) {
+	var data strings.Builder
+	for _, ev := range env {
+		data.WriteString(ev + "\n")
+	}
+
+	f, err := ioutil.TempFile("", "juju-docker-env-")
+	if err != nil {
+		return "", fmt.Errorf("can't create env file: %s", err)
+	}
+	err = f.Chmod(0600)
+	if err == nil {
+		_, err = f.WriteString(data.String())
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("can't write env file: %s", err)
+	}
+	return f.Name(), nil
+}
+
+// redactedError is an error whose message has had secrets removed.
+type redactedError struct {
+	err error
+	msg string
+}
+
+// Error implements error.
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the underlying error, so that it may still be
+// classified.
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError replaces any of the secrets in the error's message.
+func redactError(err error, secrets []string) error {
+	if err == nil || len(secrets) == 0 {
+		return err
+	}
+	msg := err.Error()
+	for _, secret := range secrets {
+		msg = strings.Replace(msg, secret, redacted, -1)
+	}
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{err: err, msg: msg}
+}
@@ -0,0 +1,168 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+// These are the classes of docker failure that callers may need to
+// tell apart. Use errors.Is, or the Is* helpers, to check for them.
+var (
+	ErrNotFound          = errors.New("no such container")
+	ErrImageNotFound     = errors.New("image not found")
+	ErrConflict          = errors.New("conflict")
+	ErrDaemonUnavailable = errors.New("docker daemon unavailable")
+	ErrPermissionDenied  = errors.New("permission denied")
+)
+
+// Error is a docker failure that has been classified.
+type Error struct {
+	// Kind is the class of failure (e.g. ErrNotFound).
+	Kind error
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Is reports whether the error is of the target class.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsNotFound reports whether the error is because the container
+// does not exist.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsImageNotFound reports whether the error is because the image
+// does not exist (locally or in the registry).
+func IsImageNotFound(err error) bool {
+	return errors.Is(err, ErrImageNotFound)
+}
+
+// IsConflict reports whether the error is because the operation
+// conflicts with the container's current state, or with another
+// container (e.g. the name is already in use).
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
+// IsDaemonUnavailable reports whether the error is because the
+// docker daemon could not be reached.
+func IsDaemonUnavailable(err error) bool {
+	return errors.Is(err, ErrDaemonUnavailable)
+}
+
+// IsPermissionDenied reports whether the error is because access to
+// the docker daemon was refused.
+func IsPermissionDenied(err error) bool {
+	return errors.Is(err, ErrPermissionDenied)
+}
+
+// These match docker's own phrasing of some failures, so that the same
+// words in other output (e.g. from a command run in a container) are
+// not mistaken for them.
+var (
+	socketPermissionDenied = regexp.MustCompile(`permission denied while trying to connect to the docker daemon|dial unix \S+: (connect: )?permission denied`)
+	daemonConflict         = regexp.MustCompile(`(^|daemon: )conflict[.:] |is already in use by container`)
+	containerStateConflict = regexp.MustCompile(`container \S+ is (not running|already paused|not paused)`)
+)
+
+// classifyMessage returns the class of failure described by the
+// error message docker reported, or nil if it isn't recognised. The
+// order matters since, for instance, a permission problem is also
+// reported as a failure to connect to the daemon.
+func classifyMessage(msg string) error {
+	msg = strings.ToLower(msg)
+	switch {
+	case socketPermissionDenied.MatchString(msg):
+		return ErrPermissionDenied
+	case strings.Contains(msg, "cannot connect to the docker daemon"),
+		strings.Contains(msg, "is the docker daemon running"),
+		strings.Contains(msg, "error during connect"):
+		return ErrDaemonUnavailable
+	case strings.Contains(msg, "unable to find image"),
+		strings.Contains(msg, "no such image:"),
+		strings.Contains(msg, "pull access denied"),
+		strings.Contains(msg, "repository does not exist"),
+		strings.Contains(msg, "manifest unknown"):
+		return ErrImageNotFound
+	case strings.Contains(msg, "no such container"),
+		strings.Contains(msg, "no such object"),
+		strings.Contains(msg, "no such image or container"),
+		strings.Contains(msg, "no such id"):
+		return ErrNotFound
+	case daemonConflict.MatchString(msg),
+		containerStateConflict.MatchString(msg):
+		return ErrConflict
+	}
+	return nil
+}
+
+// classifyCLIError classifies a failure of the docker CLI based on
+// what it wrote to stderr. Unrecognised errors are returned as-is.
+func classifyCLIError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	kind := classifyMessage(err.Error())
+	if kind == nil {
+		return err
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// classifyAPIError classifies a failed request to the docker API
+// based on the response status and message.
+func classifyAPIError(status int, msg string, err error) error {
+	var kind error
+	switch status {
+	case http.StatusNotFound:
+		kind = ErrNotFound
+		if classifyMessage(msg) == ErrImageNotFound {
+			kind = ErrImageNotFound
+		}
+	case http.StatusConflict:
+		kind = ErrConflict
+	case http.StatusUnauthorized, http.StatusForbidden:
+		kind = ErrPermissionDenied
+	default:
+		kind = classifyMessage(msg)
+	}
+	if kind == nil {
+		return err
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// classifyDialError classifies a failure to send a request to the
+// docker API. Unrecognised errors are returned as-is.
+func classifyDialError(err error) error {
+	var kind error
+	switch {
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+		kind = ErrPermissionDenied
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ENOENT):
+		kind = ErrDaemonUnavailable
+	default:
+		return err
+	}
+	return &Error{Kind: kind, Err: err}
+}
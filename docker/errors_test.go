@@ -0,0 +1,172 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker_test
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"regexp"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju-process-docker/docker"
+)
+
+var _ = gc.Suite(&errorsSuite{})
+
+type errorsSuite struct {
+	fakeDaemonSuite
+}
+
+var cliErrorTests = []struct {
+	stderr string
+	kind   error
+}{{
+	stderr: "Error response from daemon: no such id: sad_perlman",
+	kind:   docker.ErrNotFound,
+}, {
+	stderr: "Error: No such image or container: sad_perlman",
+	kind:   docker.ErrNotFound,
+}, {
+	stderr: "Error: No such object: sad_perlman",
+	kind:   docker.ErrNotFound,
+}, {
+	stderr: "Error response from daemon: No such container: sad_perlman",
+	kind:   docker.ErrNotFound,
+}, {
+	stderr: `Error response from daemon: Conflict. The name "spam" is already in use by container b508c7d5c272.`,
+	kind:   docker.ErrConflict,
+}, {
+	stderr: "Error response from daemon: Container b508c7d5c272 is not running",
+	kind:   docker.ErrConflict,
+}, {
+	stderr: "Unable to find image 'my-spam:latest' locally\nPulling repository docker.io/library/my-spam\nError: image library/my-spam:latest not found",
+	kind:   docker.ErrImageNotFound,
+}, {
+	stderr: "Error response from daemon: pull access denied for my-spam, repository does not exist or may require 'docker login'",
+	kind:   docker.ErrImageNotFound,
+}, {
+	stderr: "Error response from daemon: No such image: my-spam:latest",
+	kind:   docker.ErrImageNotFound,
+}, {
+	stderr: "Cannot connect to the Docker daemon. Is the docker daemon running on this host?",
+	kind:   docker.ErrDaemonUnavailable,
+}, {
+	stderr: "Get http:///var/run/docker.sock/v1.20/containers/json: dial unix /var/run/docker.sock: permission denied. Are you trying to connect to a TLS-enabled daemon without TLS?",
+	kind:   docker.ErrPermissionDenied,
+}, {
+	stderr: "Got permission denied while trying to connect to the Docker daemon socket at unix:///var/run/docker.sock",
+	kind:   docker.ErrPermissionDenied,
+}, {
+	stderr: "Error response from daemon: conflict: unable to remove repository reference \"my-spam\" (must force) - container b508c7d5c272 is using its referenced image fb434121fc77",
+	kind:   docker.ErrConflict,
+}, {
+	stderr: "Error response from daemon: Cannot unpause container sad_perlman: Container b508c7d5c272 is not paused",
+	kind:   docker.ErrConflict,
+}, {
+	stderr: `docker: Error response from daemon: OCI runtime create failed: container_linux.go:349: starting container process caused "exec: \"/entrypoint.sh\": permission denied": unknown.`,
+	kind:   nil,
+}, {
+	stderr: "Error response from daemon: write /var/lib/docker/tmp/conflict-resolution.json: no space left on device",
+	kind:   nil,
+}, {
+	stderr: "nginx is not running",
+	kind:   nil,
+}, {
+	stderr: "something unexpected happened",
+	kind:   nil,
+}}
+
+func (errorsSuite) TestCLIErrors(c *gc.C) {
+	kinds := []error{
+		docker.ErrNotFound,
+		docker.ErrImageNotFound,
+		docker.ErrConflict,
+		docker.ErrDaemonUnavailable,
+		docker.ErrPermissionDenied,
+	}
+	for i, test := range cliErrorTests {
+		c.Logf("test %d: %q", i, test.stderr)
+		client, fake := newClient()
+		fake.calls = []runDockerCall{{err: test.stderr}}
+
+		err := client.Stop("sad_perlman")
+
+		c.Check(err, gc.ErrorMatches, "exit status 1: "+regexp.QuoteMeta(test.stderr))
+		for _, kind := range kinds {
+			c.Check(errors.Is(err, kind), gc.Equals, kind == test.kind, gc.Commentf("%v", kind))
+		}
+	}
+}
+
+func (errorsSuite) TestPredicates(c *gc.C) {
+	err := &docker.Error{Kind: docker.ErrNotFound, Err: errors.New("boom")}
+
+	c.Check(err, gc.ErrorMatches, "boom")
+	c.Check(docker.IsNotFound(err), jc.IsTrue)
+	c.Check(docker.IsImageNotFound(err), jc.IsFalse)
+	c.Check(docker.IsConflict(err), jc.IsFalse)
+	c.Check(docker.IsDaemonUnavailable(err), jc.IsFalse)
+	c.Check(docker.IsPermissionDenied(err), jc.IsFalse)
+	c.Check(docker.IsNotFound(errors.New("no such container")), jc.IsFalse)
+}
+
+var apiErrorTests = []struct {
+	status int
+	body   string
+	kind   error
+}{{
+	status: http.StatusNotFound,
+	body:   `{"message":"No such container: sad_perlman"}`,
+	kind:   docker.ErrNotFound,
+}, {
+	status: http.StatusNotFound,
+	body:   "no such id: sad_perlman",
+	kind:   docker.ErrNotFound,
+}, {
+	status: http.StatusNotFound,
+	body:   `{"message":"No such image: my-spam:latest"}`,
+	kind:   docker.ErrImageNotFound,
+}, {
+	status: http.StatusConflict,
+	body:   `{"message":"Conflict. The name \"spam\" is already in use by container b508c7d5c272."}`,
+	kind:   docker.ErrConflict,
+}, {
+	status: http.StatusForbidden,
+	body:   `{"message":"authorization denied by plugin"}`,
+	kind:   docker.ErrPermissionDenied,
+}, {
+	status: http.StatusInternalServerError,
+	body:   `{"message":"something unexpected happened"}`,
+	kind:   nil,
+}}
+
+func (s *errorsSuite) TestAPIErrors(c *gc.C) {
+	for i, test := range apiErrorTests {
+		c.Logf("test %d: %d %s", i, test.status, test.body)
+		client, _ := s.newAPIClient(c, apiResponse{status: test.status, body: test.body})
+
+		_, err := client.Inspect("sad_perlman")
+
+		c.Check(err, gc.NotNil)
+		if test.kind == nil {
+			_, ok := err.(*docker.Error)
+			c.Check(ok, jc.IsFalse)
+			continue
+		}
+		c.Check(errors.Is(err, test.kind), jc.IsTrue)
+	}
+}
+
+func (errorsSuite) TestAPIDaemonUnavailable(c *gc.C) {
+	path := filepath.Join(c.MkDir(), "docker.sock")
+	client, err := docker.NewAPIClientForHost("unix://" + path)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.Inspect("sad_perlman")
+
+	c.Check(docker.IsDaemonUnavailable(err), jc.IsTrue)
+}
@@ -6,6 +6,9 @@ package docker
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 )
@@ -25,32 +28,197 @@ func ParseInfoJSON(id string, data []byte) (*Info, error) {
 	return &infos[0], nil
 }
 
+// ParseInfosJSON converts the JSON output of docker inspect, for
+// several containers, into a list of Info. The list holds only the
+// containers that were found, in the order they were requested.
+func ParseInfosJSON(ids []string, data []byte) ([]*Info, error) {
+	var infos []Info
+	if err := json.Unmarshal(data, &infos); err != nil {
+		return nil, fmt.Errorf("can't decode response from docker inspect %s: %s", strings.Join(ids, " "), err)
+	}
+	if len(infos) > len(ids) {
+		return nil, fmt.Errorf("too many status values returned from docker inspect %s", strings.Join(ids, " "))
+	}
+	results := make([]*Info, len(infos))
+	for i := range infos {
+		results[i] = &infos[i]
+	}
+	return results, nil
+}
+
 // Info holds all available information about a docker container.
 type Info types.ContainerJSON
 
 // These are the different possible states of a container.
 const (
 	StateUnknown    = ""
+	StateCreated    = "Created"
 	StateRunning    = "Running"
 	StatePaused     = "Paused"
 	StateRestarting = "Restarting"
+	StateRemoving   = "Removing"
+	StateExited     = "Exited"
 	StateOOMKilled  = "OOMKilled"
 	StateDead       = "Dead"
 )
 
+// statusStates maps the status that newer versions of docker report
+// for a container to its state.
+var statusStates = map[string]string{
+	"created":    StateCreated,
+	"running":    StateRunning,
+	"paused":     StatePaused,
+	"restarting": StateRestarting,
+	"removing":   StateRemoving,
+	"exited":     StateExited,
+	"dead":       StateDead,
+}
+
 // StateValue returns the label for the current state of the container.
+// A container that exited after running out of memory is reported as
+// StateOOMKilled rather than StateExited.
 func (info Info) StateValue() string {
+	if info.ContainerJSONBase == nil || info.State == nil {
+		return StateUnknown
+	}
+	state, ok := statusStates[info.State.Status]
+	if !ok {
+		// Older versions of docker do not report the status.
+		state = info.stateFromFlags()
+	}
+	if state == StateExited && info.State.OOMKilled {
+		return StateOOMKilled
+	}
+	return state
+}
+
+// stateFromFlags works out the state of the container from the flags
+// and timestamps in its state. docker sets Running as well as Paused
+// or Restarting, so those are checked first.
+func (info Info) stateFromFlags() string {
 	switch {
+	case info.State.Paused:
+		return StatePaused
+	case info.State.Restarting:
+		return StateRestarting
 	case info.State.Running:
 		return StateRunning
 	case info.State.OOMKilled:
 		return StateOOMKilled
 	case info.State.Dead:
 		return StateDead
-	case info.State.Restarting:
-		return StateRestarting
-	case info.State.Paused:
-		return StatePaused
+	case !isZeroTime(info.State.FinishedAt):
+		return StateExited
+	case isZeroTime(info.State.StartedAt):
+		return StateCreated
 	}
 	return StateUnknown
 }
+
+// isZeroTime reports whether the timestamp from docker is unset.
+func isZeroTime(timestamp string) bool {
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	return err != nil || t.IsZero()
+}
+
+// exitCodeKilled is the exit code docker reports for a container
+// whose main process was killed with SIGKILL (128+9).
+const exitCodeKilled = 137
+
+// Killed reports whether the container's main process was killed with
+// SIGKILL (e.g. after failing to stop in time), rather than exiting on
+// its own or being killed for running out of memory.
+func (info Info) Killed() bool {
+	if info.ContainerJSONBase == nil || info.State == nil {
+		return false
+	}
+	if info.State.Running || info.State.OOMKilled {
+		return false
+	}
+	return info.State.ExitCode == exitCodeKilled
+}
+
+// Entrypoint returns the container's entrypoint, as an argv list.
+func (info Info) Entrypoint() []string {
+	if info.Config == nil {
+		return nil
+	}
+	return info.Config.Entrypoint.Slice()
+}
+
+// WorkDir returns the working directory of the container's command.
+func (info Info) WorkDir() string {
+	if info.Config == nil {
+		return ""
+	}
+	return info.Config.WorkingDir
+}
+
+// User returns the user that the container's command runs as.
+func (info Info) User() string {
+	if info.Config == nil {
+		return ""
+	}
+	return info.Config.User
+}
+
+// Resources returns the limits on the resources the container may use.
+// Limits that this version of docker does not report (memory
+// reservation, CPUs and pids limit) are left unset.
+func (info Info) Resources() Resources {
+	if info.ContainerJSONBase == nil || info.HostConfig == nil {
+		return Resources{}
+	}
+	hc := info.HostConfig
+	return Resources{
+		Memory:         ByteSize(hc.Memory),
+		MemorySwap:     ByteSize(hc.MemorySwap),
+		CPUShares:      hc.CPUShares,
+		CPUPeriod:      time.Duration(hc.CPUPeriod) * time.Microsecond,
+		CPUQuota:       time.Duration(hc.CPUQuota) * time.Microsecond,
+		CpusetCPUs:     hc.CpusetCpus,
+		BlkioWeight:    uint16(hc.BlkioWeight),
+		OOMKillDisable: hc.OomKillDisable,
+	}
+}
+
+// RestartPolicy returns the container's restart policy. The number of
+// times the container has been restarted is reported in RestartCount.
+func (info Info) RestartPolicy() RestartPolicy {
+	if info.ContainerJSONBase == nil || info.HostConfig == nil {
+		return RestartPolicy{}
+	}
+	return RestartPolicy{
+		Name:       RestartPolicyName(info.HostConfig.RestartPolicy.Name),
+		MaxRetries: info.HostConfig.RestartPolicy.MaximumRetryCount,
+	}
+}
+
+// Ports returns the container's published ports, as bound on the host,
+// with one result per port. It is empty until the container starts.
+func (info Info) Ports() []PortAssignment {
+	if info.ContainerJSONBase == nil || info.NetworkSettings == nil {
+		return nil
+	}
+	var ports []PortAssignment
+	for port, bindings := range info.NetworkSettings.Ports {
+		internal, err := strconv.Atoi(port.Port())
+		if err != nil {
+			continue
+		}
+		for _, binding := range bindings {
+			external, err := strconv.Atoi(binding.HostPort)
+			if err != nil {
+				continue
+			}
+			ports = append(ports, PortAssignment{
+				HostIP:   binding.HostIP,
+				External: external,
+				Internal: internal,
+				Protocol: port.Proto(),
+			})
+		}
+	}
+	sortPorts(ports)
+	return ports
+}
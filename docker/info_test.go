@@ -4,6 +4,9 @@
 package docker_test
 
 import (
+	"strings"
+	"time"
+
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/daemon/network"
 	"github.com/docker/docker/pkg/nat"
@@ -50,6 +53,120 @@ func (infoSuite) TestParseInfoJSONMultiple(c *gc.C) {
 	c.Assert(err, gc.ErrorMatches, "multiple status values returned from docker inspect id")
 }
 
+func (infoSuite) TestParseInfosJSON(c *gc.C) {
+	b := []byte(`[{"Name":"/foo"},{"Name":"/bar"}]`)
+	infos, err := docker.ParseInfosJSON([]string{"foo", "bar", "baz"}, b)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(infos, gc.HasLen, 2)
+	c.Check(infos[0].Name, gc.Equals, "/foo")
+	c.Check(infos[1].Name, gc.Equals, "/bar")
+}
+
+func (infoSuite) TestParseInfosJSONEmpty(c *gc.C) {
+	infos, err := docker.ParseInfosJSON([]string{"foo"}, []byte(`[]`))
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(infos, gc.HasLen, 0)
+}
+
+func (infoSuite) TestParseInfosJSONTooMany(c *gc.C) {
+	b := []byte(`[{"Name":"/foo"},{"Name":"/bar"}]`)
+	_, err := docker.ParseInfosJSON([]string{"foo"}, b)
+	c.Assert(err, gc.ErrorMatches, "too many status values returned from docker inspect foo")
+}
+
+func (infoSuite) TestParseInfosJSONNone(c *gc.C) {
+	_, err := docker.ParseInfosJSON([]string{"foo", "bar"}, []byte("not json"))
+	c.Assert(err, gc.ErrorMatches, "can't decode response from docker inspect foo bar.*")
+}
+
+func (infoSuite) TestEntrypointWorkDirUser(c *gc.C) {
+	info := docker.Info(types.ContainerJSON{
+		Config: &runconfig.Config{
+			Entrypoint: runconfig.NewEntrypoint("/bin/sh", "-c"),
+			WorkingDir: "/var/lib/spam",
+			User:       "1000:1000",
+		},
+	})
+
+	c.Check(info.Entrypoint(), jc.DeepEquals, []string{"/bin/sh", "-c"})
+	c.Check(info.WorkDir(), gc.Equals, "/var/lib/spam")
+	c.Check(info.User(), gc.Equals, "1000:1000")
+}
+
+func (infoSuite) TestEntrypointWorkDirUserDefaults(c *gc.C) {
+	info := docker.Info(*fakeInfo)
+
+	c.Check(info.Entrypoint(), gc.HasLen, 0)
+	c.Check(info.WorkDir(), gc.Equals, "/cowsay")
+	c.Check(info.User(), gc.Equals, "")
+}
+
+func (infoSuite) TestResources(c *gc.C) {
+	info := docker.Info(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			HostConfig: &runconfig.HostConfig{
+				Memory:         512 << 20,
+				MemorySwap:     -1,
+				CPUShares:      512,
+				CPUPeriod:      100000,
+				CPUQuota:       50000,
+				CpusetCpus:     "0-3",
+				BlkioWeight:    500,
+				OomKillDisable: true,
+			},
+		},
+	})
+
+	c.Check(info.Resources(), jc.DeepEquals, docker.Resources{
+		Memory:         512 * docker.MiB,
+		MemorySwap:     -1,
+		CPUShares:      512,
+		CPUPeriod:      100 * time.Millisecond,
+		CPUQuota:       50 * time.Millisecond,
+		CpusetCPUs:     "0-3",
+		BlkioWeight:    500,
+		OOMKillDisable: true,
+	})
+}
+
+func (infoSuite) TestResourcesNoHostConfig(c *gc.C) {
+	info := docker.Info(types.ContainerJSON{})
+	c.Check(info.Resources(), jc.DeepEquals, docker.Resources{})
+
+	info = docker.Info(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{},
+	})
+	c.Check(info.Resources(), jc.DeepEquals, docker.Resources{})
+}
+
+func (infoSuite) TestRestartPolicy(c *gc.C) {
+	data := strings.NewReplacer(
+		`"RestartCount": 0`, `"RestartCount": 4`,
+		`"Name": "no"`, `"Name": "on-failure"`,
+		`"MaximumRetryCount": 0`, `"MaximumRetryCount": 5`,
+	).Replace(fakeInspectOutput)
+	info, err := docker.ParseInfoJSON("id", []byte(data))
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(info.RestartPolicy(), jc.DeepEquals, docker.RestartPolicy{
+		Name:       docker.RestartOnFailure,
+		MaxRetries: 5,
+	})
+	c.Check(info.RestartCount, gc.Equals, 4)
+}
+
+func (infoSuite) TestRestartPolicyNoHostConfig(c *gc.C) {
+	info := docker.Info(types.ContainerJSON{})
+	c.Check(info.RestartPolicy(), jc.DeepEquals, docker.RestartPolicy{})
+
+	info = docker.Info(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{},
+	})
+	c.Check(info.RestartPolicy(), jc.DeepEquals, docker.RestartPolicy{})
+}
+
 func (infoSuite) TestStateValue(c *gc.C) {
 	info := docker.Info(types.ContainerJSON{
 		ContainerJSONBase: &types.ContainerJSONBase{
@@ -70,6 +187,94 @@ func (infoSuite) TestStateValue(c *gc.C) {
 	c.Check(state, gc.Equals, docker.StateRunning)
 }
 
+// stateValueTests holds the state reported by docker inspect for
+// containers in various states, from several versions of docker.
+var stateValueTests = []struct {
+	about    string
+	state    string
+	expected string
+}{{
+	about:    "1.8 created",
+	state:    `{"Running":false,"Paused":false,"Restarting":false,"OOMKilled":false,"Dead":false,"Pid":0,"ExitCode":0,"Error":"","StartedAt":"0001-01-01T00:00:00Z","FinishedAt":"0001-01-01T00:00:00Z"}`,
+	expected: docker.StateCreated,
+}, {
+	about:    "1.8 running",
+	state:    `{"Running":true,"Paused":false,"Restarting":false,"OOMKilled":false,"Dead":false,"Pid":11820,"ExitCode":0,"Error":"","StartedAt":"2015-06-25T11:05:53.8401024Z","FinishedAt":"0001-01-01T00:00:00Z"}`,
+	expected: docker.StateRunning,
+}, {
+	about:    "1.8 paused",
+	state:    `{"Running":true,"Paused":true,"Restarting":false,"OOMKilled":false,"Dead":false,"Pid":11820,"ExitCode":0,"Error":"","StartedAt":"2015-06-25T11:05:53.8401024Z","FinishedAt":"0001-01-01T00:00:00Z"}`,
+	expected: docker.StatePaused,
+}, {
+	about:    "1.8 restarting",
+	state:    `{"Running":true,"Paused":false,"Restarting":true,"OOMKilled":false,"Dead":false,"Pid":0,"ExitCode":1,"Error":"","StartedAt":"2015-06-25T11:05:53.8401024Z","FinishedAt":"2015-06-25T11:06:00.1207443Z"}`,
+	expected: docker.StateRestarting,
+}, {
+	about:    "1.8 exited",
+	state:    `{"Running":false,"Paused":false,"Restarting":false,"OOMKilled":false,"Dead":false,"Pid":0,"ExitCode":0,"Error":"","StartedAt":"2015-06-25T11:05:53.8401024Z","FinishedAt":"2015-06-25T11:06:23.9023451Z"}`,
+	expected: docker.StateExited,
+}, {
+	about:    "1.8 OOM killed",
+	state:    `{"Running":false,"Paused":false,"Restarting":false,"OOMKilled":true,"Dead":false,"Pid":0,"ExitCode":137,"Error":"","StartedAt":"2015-06-25T11:05:53.8401024Z","FinishedAt":"2015-06-25T11:06:23.9023451Z"}`,
+	expected: docker.StateOOMKilled,
+}, {
+	about:    "1.8 dead",
+	state:    `{"Running":false,"Paused":false,"Restarting":false,"OOMKilled":false,"Dead":true,"Pid":0,"ExitCode":0,"Error":"","StartedAt":"2015-06-25T11:05:53.8401024Z","FinishedAt":"2015-06-25T11:06:23.9023451Z"}`,
+	expected: docker.StateDead,
+}, {
+	about:    "1.10 created",
+	state:    `{"Status":"created","Running":false,"Paused":false,"Restarting":false,"OOMKilled":false,"Dead":false,"Pid":0,"ExitCode":0,"Error":"","StartedAt":"0001-01-01T00:00:00Z","FinishedAt":"0001-01-01T00:00:00Z"}`,
+	expected: docker.StateCreated,
+}, {
+	about:    "1.10 exited",
+	state:    `{"Status":"exited","Running":false,"Paused":false,"Restarting":false,"OOMKilled":false,"Dead":false,"Pid":0,"ExitCode":0,"Error":"","StartedAt":"2016-03-01T09:12:43.412937431Z","FinishedAt":"2016-03-01T09:12:43.496374113Z"}`,
+	expected: docker.StateExited,
+}, {
+	about:    "1.10 OOM killed",
+	state:    `{"Status":"exited","Running":false,"Paused":false,"Restarting":false,"OOMKilled":true,"Dead":false,"Pid":0,"ExitCode":137,"Error":"","StartedAt":"2016-03-01T09:12:43.412937431Z","FinishedAt":"2016-03-01T09:13:02.120040321Z"}`,
+	expected: docker.StateOOMKilled,
+}, {
+	about:    "1.10 paused",
+	state:    `{"Status":"paused","Running":true,"Paused":true,"Restarting":false,"OOMKilled":false,"Dead":false,"Pid":2291,"ExitCode":0,"Error":"","StartedAt":"2016-03-01T09:12:43.412937431Z","FinishedAt":"0001-01-01T00:00:00Z"}`,
+	expected: docker.StatePaused,
+}, {
+	about:    "20.10 running",
+	state:    `{"Status":"running","Running":true,"Paused":false,"Restarting":false,"OOMKilled":false,"Dead":false,"Pid":4012,"ExitCode":0,"Error":"","StartedAt":"2021-06-14T15:04:11.781230612Z","FinishedAt":"0001-01-01T00:00:00Z","Health":{"Status":"healthy","FailingStreak":0,"Log":[]}}`,
+	expected: docker.StateRunning,
+}, {
+	about:    "20.10 removing",
+	state:    `{"Status":"removing","Running":false,"Paused":false,"Restarting":false,"OOMKilled":false,"Dead":false,"Pid":0,"ExitCode":0,"Error":"","StartedAt":"2021-06-14T15:04:11.781230612Z","FinishedAt":"2021-06-14T15:10:45.30913542Z"}`,
+	expected: docker.StateRemoving,
+}, {
+	about:    "20.10 restarting",
+	state:    `{"Status":"restarting","Running":true,"Paused":false,"Restarting":true,"OOMKilled":false,"Dead":false,"Pid":0,"ExitCode":1,"Error":"","StartedAt":"2021-06-14T15:04:11.781230612Z","FinishedAt":"2021-06-14T15:04:12.0084518Z"}`,
+	expected: docker.StateRestarting,
+}, {
+	about:    "20.10 dead",
+	state:    `{"Status":"dead","Running":false,"Paused":false,"Restarting":false,"OOMKilled":false,"Dead":true,"Pid":0,"ExitCode":0,"Error":"","StartedAt":"2021-06-14T15:04:11.781230612Z","FinishedAt":"2021-06-14T15:10:45.30913542Z"}`,
+	expected: docker.StateDead,
+}}
+
+func (infoSuite) TestStateValueVersions(c *gc.C) {
+	for i, test := range stateValueTests {
+		c.Logf("test %d: %s", i, test.about)
+
+		data := `[{"Id":"b508c7d5c272","State":` + test.state + `}]`
+		info, err := docker.ParseInfoJSON("b508c7d5c272", []byte(data))
+		c.Assert(err, jc.ErrorIsNil)
+
+		c.Check(info.StateValue(), gc.Equals, test.expected)
+	}
+}
+
+func (infoSuite) TestStateValueNoState(c *gc.C) {
+	info := docker.Info(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{},
+	})
+
+	c.Check(info.StateValue(), gc.Equals, docker.StateUnknown)
+}
+
 const fakeInspectOutput = `
 [
 {
@@ -325,3 +530,45 @@ var fakeInfo = &types.ContainerJSON{
 		Labels:          map[string]string{},
 	},
 }
+
+func (infoSuite) TestKilled(c *gc.C) {
+	for i, test := range []struct {
+		state    types.ContainerState
+		expected bool
+	}{{
+		state:    types.ContainerState{Running: true},
+		expected: false,
+	}, {
+		state:    types.ContainerState{ExitCode: 0},
+		expected: false,
+	}, {
+		state:    types.ContainerState{ExitCode: 143},
+		expected: false,
+	}, {
+		state:    types.ContainerState{ExitCode: 137},
+		expected: true,
+	}, {
+		state:    types.ContainerState{ExitCode: 137, OOMKilled: true},
+		expected: false,
+	}} {
+		c.Logf("test %d: %#v", i, test.state)
+		state := test.state
+		info := docker.Info(types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				State: &state,
+			},
+		})
+
+		c.Check(info.Killed(), gc.Equals, test.expected)
+	}
+}
+
+func (infoSuite) TestKilledNoState(c *gc.C) {
+	info := docker.Info(types.ContainerJSON{})
+	c.Check(info.Killed(), jc.IsFalse)
+
+	info = docker.Info(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{},
+	})
+	c.Check(info.Killed(), jc.IsFalse)
+}
@@ -0,0 +1,50 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"errors"
+)
+
+// These are the labels Juju sets on the containers it launches, so
+// that it can find them again.
+const (
+	// LabelModelUUID identifies the model the container belongs to.
+	LabelModelUUID = "com.canonical.juju.model-uuid"
+	// LabelUnit identifies the unit the container belongs to.
+	LabelUnit = "com.canonical.juju.unit"
+	// LabelProcess identifies the workload process the container
+	// runs for the unit.
+	LabelProcess = "com.canonical.juju.process"
+)
+
+// errNoLabels is returned when looking up containers without any
+// labels, which would otherwise match every container.
+var errNoLabels = errors.New("no labels to match")
+
+// Labels returns the labels set on the container.
+func (info Info) Labels() map[string]string {
+	if info.Config == nil {
+		return nil
+	}
+	return info.Config.Labels
+}
+
+// ModelUUID returns the UUID of the Juju model the container belongs
+// to, if it was launched by Juju.
+func (info Info) ModelUUID() string {
+	return info.Labels()[LabelModelUUID]
+}
+
+// Unit returns the name of the Juju unit the container belongs to, if
+// it was launched by Juju.
+func (info Info) Unit() string {
+	return info.Labels()[LabelUnit]
+}
+
+// Process returns the name of the workload process the container runs,
+// if it was launched by Juju.
+func (info Info) Process() string {
+	return info.Labels()[LabelProcess]
+}
@@ -0,0 +1,73 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker_test
+
+import (
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/runconfig"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju-process-docker/docker"
+)
+
+var _ = gc.Suite(&labelsSuite{})
+
+type labelsSuite struct{}
+
+func (labelsSuite) TestJujuLabels(c *gc.C) {
+	info := docker.Info(types.ContainerJSON{
+		Config: &runconfig.Config{
+			Labels: map[string]string{
+				docker.LabelModelUUID: "deadbeef-0bad-400d-8000-4b1d0d06f00d",
+				docker.LabelUnit:      "spam/0",
+				docker.LabelProcess:   "eggs",
+				"flavour":             "ham",
+			},
+		},
+	})
+
+	c.Check(info.ModelUUID(), gc.Equals, "deadbeef-0bad-400d-8000-4b1d0d06f00d")
+	c.Check(info.Unit(), gc.Equals, "spam/0")
+	c.Check(info.Process(), gc.Equals, "eggs")
+	c.Check(info.Labels(), gc.HasLen, 4)
+}
+
+func (labelsSuite) TestJujuLabelsMissing(c *gc.C) {
+	info := docker.Info(*fakeInfo)
+
+	c.Check(info.Labels(), gc.HasLen, 0)
+	c.Check(info.ModelUUID(), gc.Equals, "")
+	c.Check(info.Unit(), gc.Equals, "")
+	c.Check(info.Process(), gc.Equals, "")
+}
+
+func (labelsSuite) TestFindByLabels(c *gc.C) {
+	client, fake := newClient(fakePSOutput)
+
+	summaries, err := client.FindByLabels(map[string]string{
+		docker.LabelUnit:      "spam/0",
+		docker.LabelModelUUID: "deadbeef-0bad-400d-8000-4b1d0d06f00d",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(summaries, gc.HasLen, 2)
+	c.Check(fake.calls[0].commandIn, gc.Equals, "ps")
+	c.Check(fake.calls[0].argsIn, jc.DeepEquals, []string{
+		"--no-trunc",
+		"--format", "{{json .}}",
+		"--all",
+		"--filter", "label=com.canonical.juju.model-uuid=deadbeef-0bad-400d-8000-4b1d0d06f00d",
+		"--filter", "label=com.canonical.juju.unit=spam/0",
+	})
+}
+
+func (labelsSuite) TestFindByLabelsNone(c *gc.C) {
+	client, fake := newClient()
+
+	_, err := client.FindByLabels(nil)
+
+	c.Check(err, gc.ErrorMatches, "no labels to match")
+	c.Check(fake.index, gc.Equals, 0)
+}
@@ -0,0 +1,245 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ContainerSummary holds the basic information about a container that
+// is reported when listing containers.
+type ContainerSummary struct {
+	// ID is the container's full ID.
+	ID string
+	// Names holds the container's name and those of any links to it.
+	Names []string
+	// Image is the image the container was created from, as given
+	// when creating it.
+	Image string
+	// State is docker's label for the container's current state
+	// (e.g. "running", "exited").
+	State string
+	// Status is a human-readable description of the container's
+	// status (e.g. "Up 2 hours").
+	Status string
+	// Labels holds the labels set on the container.
+	Labels map[string]string
+	// Ports holds the container's published ports.
+	Ports []PortAssignment
+}
+
+// psEntry is a single line of docker ps output, as formatted with
+// "{{json .}}". Every field is rendered as a string.
+type psEntry struct {
+	ID     string
+	Names  string
+	Image  string
+	State  string
+	Status string
+	Labels string
+	Ports  string
+}
+
+// ParseListJSON converts the output of docker ps, formatted with
+// "{{json .}}", into a list of summaries.
+func ParseListJSON(data []byte) ([]ContainerSummary, error) {
+	var summaries []ContainerSummary
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	// A container with many labels may not fit in the scanner's
+	// default buffer, but no line can be longer than the data.
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry psEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("can't decode response from docker ps: %s", err)
+		}
+		summary, err := entry.summary()
+		if err != nil {
+			return nil, fmt.Errorf("can't decode response from docker ps: %s", err)
+		}
+		summaries = append(summaries, summary)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("can't read response from docker ps: %s", err)
+	}
+	return summaries, nil
+}
+
+// summary converts the entry into a ContainerSummary.
+func (entry psEntry) summary() (ContainerSummary, error) {
+	summary := ContainerSummary{
+		ID:     entry.ID,
+		Image:  entry.Image,
+		State:  entry.State,
+		Status: entry.Status,
+	}
+	if summary.State == "" {
+		// Older versions of docker do not report the state.
+		summary.State = stateFromStatus(entry.Status)
+	}
+
+	if entry.Names != "" {
+		summary.Names = strings.Split(entry.Names, ",")
+	}
+
+	if entry.Labels != "" {
+		summary.Labels = parsePSLabels(entry.Labels)
+	}
+
+	ports, err := parsePSPorts(entry.Ports)
+	if err != nil {
+		return ContainerSummary{}, err
+	}
+	summary.Ports = ports
+
+	return summary, nil
+}
+
+// parsePSLabels converts the labels reported by docker ps
+// (e.g. "a=1,b=2") into a map. docker joins the labels with commas
+// without escaping them, so a part without "=" is taken to belong to
+// the previous label's value. A value that contains a comma followed
+// by "=" (e.g. "a=1,b=2" as the value of one label) can't be told
+// apart from several labels, and is split up.
+func parsePSLabels(labels string) map[string]string {
+	result := make(map[string]string)
+	var last string
+	for i, label := range strings.Split(labels, ",") {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) == 1 && i > 0 {
+			result[last] += "," + label
+			continue
+		}
+		if len(parts) == 1 {
+			parts = append(parts, "")
+		}
+		last = parts[0]
+		result[last] = parts[1]
+	}
+	return result
+}
+
+// parsePSPorts converts the ports reported by docker ps
+// (e.g. "0.0.0.0:8080->80/tcp, 443/tcp") into the published
+// port mappings. Ports that are exposed but not published are
+// skipped, as are the duplicates reported for IPv4 and IPv6 (see
+// publishedPorts).
+func parsePSPorts(ports string) ([]PortAssignment, error) {
+	var assignments publishedPorts
+	for _, port := range strings.Split(ports, ",") {
+		port = strings.TrimSpace(port)
+		parts := strings.SplitN(port, "->", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		host, container := parts[0], parts[1]
+
+		// The host part is the IP address and port, where the address
+		// may be IPv6 (e.g. ":::8080" or "[::]:8080").
+		var hostIP string
+		external := host
+		if sep := strings.LastIndex(host, ":"); sep >= 0 {
+			hostIP, external = host[:sep], host[sep+1:]
+			hostIP = strings.TrimSuffix(strings.TrimPrefix(hostIP, "["), "]")
+		}
+		internal, protocol := container, "tcp"
+		if i := strings.Index(container, "/"); i >= 0 {
+			internal, protocol = container[:i], container[i+1:]
+		}
+
+		externalStart, externalEnd, err := parsePortRange(external)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q: %s", port, err)
+		}
+		internalStart, internalEnd, err := parsePortRange(internal)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q: %s", port, err)
+		}
+		if externalEnd-externalStart != internalEnd-internalStart {
+			return nil, fmt.Errorf("invalid port %q: mismatched ranges", port)
+		}
+
+		for offset := 0; offset <= internalEnd-internalStart; offset++ {
+			assignments.add(PortAssignment{
+				HostIP:   hostIP,
+				External: externalStart + offset,
+				Internal: internalStart + offset,
+				Protocol: protocol,
+			})
+		}
+	}
+	return assignments, nil
+}
+
+// publishedPorts accumulates the published ports of a container, as
+// listed by docker.
+type publishedPorts []PortAssignment
+
+// add records the port mapping. docker reports a port published on all
+// addresses once for IPv4 ("0.0.0.0") and once for IPv6 ("::"), so
+// only the IPv4 mapping is kept.
+func (ports *publishedPorts) add(pa PortAssignment) {
+	ipv4, ipv6 := pa, pa
+	ipv4.HostIP, ipv6.HostIP = "0.0.0.0", "::"
+	for i, p := range *ports {
+		switch {
+		case pa == ipv6 && p == ipv4:
+			return
+		case pa == ipv4 && p == ipv6:
+			(*ports)[i] = pa
+			return
+		}
+	}
+	*ports = append(*ports, pa)
+}
+
+// parsePortRange converts a port (e.g. "80") or range of ports
+// (e.g. "7000-7010") into the first and last port numbers.
+func parsePortRange(ports string) (int, int, error) {
+	parts := strings.SplitN(ports, "-", 2)
+	start, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(parts) == 1 {
+		return start, start, nil
+	}
+	end, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// stateFromStatus works out docker's label for the state of a container
+// from its human-readable status (e.g. "Up 2 hours (Paused)").
+func stateFromStatus(status string) string {
+	switch {
+	case strings.HasPrefix(status, "Up"):
+		if strings.HasSuffix(status, "(Paused)") {
+			return "paused"
+		}
+		return "running"
+	case strings.HasPrefix(status, "Exited"):
+		return "exited"
+	case strings.HasPrefix(status, "Created"):
+		return "created"
+	case strings.HasPrefix(status, "Restarting"):
+		return "restarting"
+	case strings.HasPrefix(status, "Removal In Progress"):
+		return "removing"
+	case strings.HasPrefix(status, "Dead"):
+		return "dead"
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker_test
+
+import (
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju-process-docker/docker"
+)
+
+var _ = gc.Suite(&listSuite{})
+
+type listSuite struct{}
+
+const fakePSOutput = `
+{"Command":"\"sleep 30\"","CreatedAt":"2015-07-20 13:41:08 +0000 UTC","ID":"b508c7d5c2722b7ac4f105fedf835789fb705f71feb6e264f542dc33cdc41232","Image":"docker/whalesay","Labels":"com.canonical.juju.unit=spam/0,flavour=","LocalVolumes":"0","Mounts":"","Names":"sad_perlman","Networks":"bridge","Ports":"0.0.0.0:8080->80/tcp, :::8080->80/tcp, 443/tcp","RunningFor":"2 hours ago","Size":"0B","State":"running","Status":"Up 2 hours"}
+{"Command":"\"eggs\"","CreatedAt":"2015-07-20 13:41:08 +0000 UTC","ID":"2b3a8c7b7c6f","Image":"eggs:1.0","Labels":"","Names":"eggs,sad_perlman/eggs","Ports":"127.0.0.1:7000-7001->7000-7001/udp","Status":"Exited (0) 5 minutes ago"}
+`
+
+func (listSuite) TestParseListJSON(c *gc.C) {
+	summaries, err := docker.ParseListJSON([]byte(fakePSOutput))
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(summaries, jc.DeepEquals, []docker.ContainerSummary{{
+		ID:     "b508c7d5c2722b7ac4f105fedf835789fb705f71feb6e264f542dc33cdc41232",
+		Names:  []string{"sad_perlman"},
+		Image:  "docker/whalesay",
+		State:  "running",
+		Status: "Up 2 hours",
+		Labels: map[string]string{
+			"com.canonical.juju.unit": "spam/0",
+			"flavour":                 "",
+		},
+		Ports: []docker.PortAssignment{
+			{HostIP: "0.0.0.0", External: 8080, Internal: 80, Protocol: "tcp"},
+		},
+	}, {
+		ID:     "2b3a8c7b7c6f",
+		Names:  []string{"eggs", "sad_perlman/eggs"},
+		Image:  "eggs:1.0",
+		State:  "exited",
+		Status: "Exited (0) 5 minutes ago",
+		Ports: []docker.PortAssignment{
+			{HostIP: "127.0.0.1", External: 7000, Internal: 7000, Protocol: "udp"},
+			{HostIP: "127.0.0.1", External: 7001, Internal: 7001, Protocol: "udp"},
+		},
+	}})
+}
+
+func (listSuite) TestParseListJSONHostIPs(c *gc.C) {
+	out := `{"ID":"sad_perlman","Ports":"[::]:8080->80/tcp, 0.0.0.0:8080->80/tcp, [::1]:9000->90/tcp, 127.0.0.1:9000->90/tcp"}`
+
+	summaries, err := docker.ParseListJSON([]byte(out))
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(summaries, gc.HasLen, 1)
+	c.Check(summaries[0].Ports, jc.DeepEquals, []docker.PortAssignment{
+		{HostIP: "0.0.0.0", External: 8080, Internal: 80, Protocol: "tcp"},
+		{HostIP: "::1", External: 9000, Internal: 90, Protocol: "tcp"},
+		{HostIP: "127.0.0.1", External: 9000, Internal: 90, Protocol: "tcp"},
+	})
+}
+
+func (listSuite) TestParseListJSONLabelsWithCommas(c *gc.C) {
+	out := `{"ID":"sad_perlman","Labels":"hosts=a,b,c,empty=,spam=eggs,x=1,y=2"}`
+
+	summaries, err := docker.ParseListJSON([]byte(out))
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(summaries, gc.HasLen, 1)
+	// A value such as "x=1,y=2" can't be told apart from two labels.
+	c.Check(summaries[0].Labels, jc.DeepEquals, map[string]string{
+		"hosts": "a,b,c",
+		"empty": "",
+		"spam":  "eggs",
+		"x":     "1",
+		"y":     "2",
+	})
+}
+
+func (listSuite) TestParseListJSONLongLine(c *gc.C) {
+	value := strings.Repeat("x", 100000)
+	out := `{"ID":"sad_perlman","Labels":"big=` + value + `"}`
+
+	summaries, err := docker.ParseListJSON([]byte(out))
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Assert(summaries, gc.HasLen, 1)
+	c.Check(summaries[0].Labels["big"], gc.Equals, value)
+}
+
+func (listSuite) TestParseListJSONEmpty(c *gc.C) {
+	summaries, err := docker.ParseListJSON([]byte("\n"))
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(summaries, gc.HasLen, 0)
+}
+
+var stateFromStatusTests = []struct {
+	status string
+	state  string
+}{
+	{"Up 2 hours", "running"},
+	{"Up 2 hours (Paused)", "paused"},
+	{"Up 2 hours (healthy)", "running"},
+	{"Exited (137) 5 seconds ago", "exited"},
+	{"Created", "created"},
+	{"Restarting (1) 3 seconds ago", "restarting"},
+	{"Removal In Progress", "removing"},
+	{"Dead", "dead"},
+	{"something new", ""},
+}
+
+func (listSuite) TestParseListJSONState(c *gc.C) {
+	for i, test := range stateFromStatusTests {
+		c.Logf("test %d: %q", i, test.status)
+		out := `{"ID":"sad_perlman","Status":"` + test.status + `"}`
+
+		summaries, err := docker.ParseListJSON([]byte(out))
+		c.Assert(err, jc.ErrorIsNil)
+
+		c.Assert(summaries, gc.HasLen, 1)
+		c.Check(summaries[0].State, gc.Equals, test.state)
+	}
+}
+
+func (listSuite) TestParseListJSONInvalid(c *gc.C) {
+	_, err := docker.ParseListJSON([]byte("not json"))
+
+	c.Check(err, gc.ErrorMatches, "can't decode response from docker ps: .*")
+}
+
+func (listSuite) TestParseListJSONBadPorts(c *gc.C) {
+	out := `{"ID":"sad_perlman","Ports":"0.0.0.0:8080-8081->80/tcp"}`
+
+	_, err := docker.ParseListJSON([]byte(out))
+
+	c.Check(err, gc.ErrorMatches, `can't decode response from docker ps: invalid port "0.0.0.0:8080-8081->80/tcp": mismatched ranges`)
+}
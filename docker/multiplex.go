@@ -0,0 +1,84 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// multiplexHeaderSize is the size of the header that precedes each
+// frame of a multiplexed stream. The first byte identifies the stream
+// (stdin, stdout or stderr) and the last four hold the frame size.
+const multiplexHeaderSize = 8
+
+// stderrStream identifies frames of a multiplexed stream that hold the
+// output written to stderr.
+const stderrStream = 2
+
+// demuxReader is an io.ReadCloser over the payload of a multiplexed
+// stream, as sent by the docker API for containers without a TTY. The
+// frames of all the streams are merged in the order they are received.
+type demuxReader struct {
+	r io.ReadCloser
+
+	// remaining is the number of bytes left in the current frame.
+	remaining uint32
+}
+
+func newDemuxReader(r io.ReadCloser) *demuxReader {
+	return &demuxReader{r: r}
+}
+
+// Read implements io.Reader.
+func (d *demuxReader) Read(p []byte) (int, error) {
+	for d.remaining == 0 {
+		var header [multiplexHeaderSize]byte
+		if _, err := io.ReadFull(d.r, header[:]); err != nil {
+			return 0, err
+		}
+		d.remaining = binary.BigEndian.Uint32(header[4:])
+	}
+
+	if uint32(len(p)) > d.remaining {
+		p = p[:d.remaining]
+	}
+	n, err := d.r.Read(p)
+	d.remaining -= uint32(n)
+	if err == io.EOF && d.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Close implements io.Closer.
+func (d *demuxReader) Close() error {
+	return d.r.Close()
+}
+
+// demultiplex copies the payload of each frame of the multiplexed stream
+// to stdout or stderr as appropriate, until the stream ends.
+func demultiplex(r io.Reader, stdout, stderr io.Writer) error {
+	var header [multiplexHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		w := stdout
+		if header[0] == stderrStream {
+			w = stderr
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+}
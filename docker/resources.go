@@ -0,0 +1,285 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ByteSize is an amount of memory, in bytes.
+type ByteSize int64
+
+// These are the units of memory that docker understands.
+const (
+	Byte ByteSize = 1
+	KiB           = 1024 * Byte
+	MiB           = 1024 * KiB
+	GiB           = 1024 * MiB
+)
+
+// byteUnits holds the suffixes docker uses for each unit of memory,
+// largest first.
+var byteUnits = []struct {
+	suffix string
+	size   ByteSize
+}{
+	{"g", GiB},
+	{"m", MiB},
+	{"k", KiB},
+	{"b", Byte},
+}
+
+// ParseByteSize converts an amount of memory in the form docker accepts
+// (e.g. "512m", "1g", "1048576") into a ByteSize. The unit is one of
+// b, k, m or g (optionally followed by "b"), and defaults to bytes.
+// "-1" is accepted as meaning unlimited.
+func ParseByteSize(s string) (ByteSize, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	if value == "-1" {
+		return -1, nil
+	}
+	unit := Byte
+	for _, u := range byteUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value, unit = strings.TrimSuffix(value, u.suffix), u.size
+			break
+		}
+		if u.suffix != "b" && strings.HasSuffix(value, u.suffix+"b") {
+			value, unit = strings.TrimSuffix(value, u.suffix+"b"), u.size
+			break
+		}
+	}
+	if !decimalNumber.MatchString(value) {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	n, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	n.Mul(n, new(big.Rat).SetInt64(int64(unit)))
+	if !n.IsInt() {
+		return 0, fmt.Errorf("invalid size %q: not a whole number of bytes", s)
+	}
+	if !n.Num().IsInt64() {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return ByteSize(n.Num().Int64()), nil
+}
+
+// decimalNumber matches a non-negative number in decimal notation,
+// without an exponent.
+var decimalNumber = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// String returns the size in the form docker accepts, using the
+// largest unit that represents it exactly.
+func (b ByteSize) String() string {
+	for _, u := range byteUnits {
+		if b > 0 && u.size > Byte && b%u.size == 0 {
+			return strconv.FormatInt(int64(b/u.size), 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(b), 10)
+}
+
+// CPUs is an amount of CPU time, as a (possibly fractional) number
+// of CPUs.
+type CPUs float64
+
+// String returns the number of CPUs in the form docker accepts.
+func (c CPUs) String() string {
+	return strconv.FormatFloat(float64(c), 'f', -1, 64)
+}
+
+// nanoCPUs returns the number of CPUs in units of 1e-9 CPUs, as the
+// API expects.
+func (c CPUs) nanoCPUs() int64 {
+	return int64(math.Round(float64(c) * 1e9))
+}
+
+// These are the limits docker imposes on resources.
+const (
+	minMemory      = 6 * MiB
+	minCPUPeriod   = time.Millisecond
+	maxCPUPeriod   = time.Second
+	minCPUQuota    = time.Millisecond
+	minBlkioWeight = 10
+	maxBlkioWeight = 1000
+)
+
+// Resources holds the limits on the resources a container may use. A
+// zero value means there is no limit (or docker's default is used).
+type Resources struct {
+	// Memory is the most memory the container may use.
+	Memory ByteSize
+	// MemorySwap is the most memory and swap combined the container
+	// may use. It requires Memory to be set. -1 means swap is
+	// unlimited.
+	MemorySwap ByteSize
+	// MemoryReservation is the memory the container is allowed when
+	// the host is short of memory. It must be less than Memory.
+	MemoryReservation ByteSize
+	// CPUShares is the container's weighting when the host's CPUs are
+	// contended, relative to other containers (the default is 1024).
+	CPUShares int64
+	// CPUPeriod is the length of the scheduling period that CPUQuota
+	// applies to.
+	CPUPeriod time.Duration
+	// CPUQuota is the CPU time the container may use in each
+	// scheduling period.
+	CPUQuota time.Duration
+	// CPUs is the number of CPUs the container may use. It is an
+	// alternative to setting CPUPeriod and CPUQuota.
+	CPUs CPUs
+	// CpusetCPUs lists the CPUs the container may run on
+	// (e.g. "0-3,5").
+	CpusetCPUs string
+	// PidsLimit is the most processes the container may run. -1 means
+	// the number is unlimited.
+	PidsLimit int64
+	// BlkioWeight is the container's weighting for block IO, between
+	// 10 and 1000.
+	BlkioWeight uint16
+	// OOMKillDisable indicates that the container should not be killed
+	// when it runs out of memory.
+	OOMKillDisable bool
+}
+
+// Validate checks that the limits are ones docker will accept. Only the
+// first problem is reported.
+func (r Resources) Validate() error {
+	if problems := r.problems(); len(problems) > 0 {
+		return problems[0].Err
+	}
+	return nil
+}
+
+// problems returns every problem with the limits, keyed by field, in
+// the order of the fields.
+func (r Resources) problems() []*FieldError {
+	verr := &ValidationError{}
+	if r.Memory < 0 {
+		verr.addf("Memory", "invalid memory %s: must not be negative", r.Memory)
+	} else if r.Memory > 0 && r.Memory < minMemory {
+		verr.addf("Memory", "invalid memory %s: must be at least %s", r.Memory, minMemory)
+	}
+	if r.MemorySwap != 0 {
+		if r.Memory == 0 {
+			verr.addf("MemorySwap", "invalid memory swap %s: memory must be set too", r.MemorySwap)
+		} else if r.MemorySwap != -1 && r.MemorySwap < r.Memory {
+			verr.addf("MemorySwap", "invalid memory swap %s: must be at least the memory (%s)", r.MemorySwap, r.Memory)
+		}
+	}
+	if r.MemoryReservation < 0 {
+		verr.addf("MemoryReservation", "invalid memory reservation %s: must not be negative", r.MemoryReservation)
+	} else if r.Memory > 0 && r.MemoryReservation > r.Memory {
+		verr.addf("MemoryReservation", "invalid memory reservation %s: must not be more than the memory (%s)", r.MemoryReservation, r.Memory)
+	}
+	if r.CPUShares < 0 {
+		verr.addf("CPUShares", "invalid CPU shares %d: must not be negative", r.CPUShares)
+	}
+	if r.CPUPeriod != 0 && (r.CPUPeriod < minCPUPeriod || r.CPUPeriod > maxCPUPeriod) {
+		verr.addf("CPUPeriod", "invalid CPU period %s: must be between %s and %s", r.CPUPeriod, minCPUPeriod, maxCPUPeriod)
+	}
+	if r.CPUQuota != 0 && r.CPUQuota < minCPUQuota {
+		verr.addf("CPUQuota", "invalid CPU quota %s: must be at least %s", r.CPUQuota, minCPUQuota)
+	}
+	if r.CPUs < 0 || math.IsNaN(float64(r.CPUs)) || math.IsInf(float64(r.CPUs), 0) {
+		verr.addf("CPUs", "invalid CPUs %s: must be a non-negative number", r.CPUs)
+	} else if r.CPUs > 0 && (r.CPUPeriod != 0 || r.CPUQuota != 0) {
+		verr.addf("CPUs", "invalid CPUs %s: can't be used with a CPU period or quota", r.CPUs)
+	}
+	if r.CpusetCPUs != "" {
+		if err := validateCPUSet(r.CpusetCPUs); err != nil {
+			verr.addf("CpusetCPUs", "invalid cpuset %q: %s", r.CpusetCPUs, err)
+		}
+	}
+	if r.PidsLimit < -1 {
+		verr.addf("PidsLimit", "invalid pids limit %d: must be -1 or more", r.PidsLimit)
+	}
+	if r.BlkioWeight != 0 && (r.BlkioWeight < minBlkioWeight || r.BlkioWeight > maxBlkioWeight) {
+		verr.addf("BlkioWeight", "invalid blkio weight %d: must be between %d and %d", r.BlkioWeight, minBlkioWeight, maxBlkioWeight)
+	}
+	return verr.Problems
+}
+
+// validateCPUSet checks that the list of CPUs (e.g. "0-3,5") is in the
+// form docker accepts.
+func validateCPUSet(cpus string) error {
+	for _, part := range strings.Split(cpus, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.ParseUint(bounds[0], 10, 16)
+		if err != nil {
+			return fmt.Errorf("bad CPU %q", bounds[0])
+		}
+		if len(bounds) == 1 {
+			continue
+		}
+		last, err := strconv.ParseUint(bounds[1], 10, 16)
+		if err != nil {
+			return fmt.Errorf("bad CPU %q", bounds[1])
+		}
+		if last < first {
+			return fmt.Errorf("bad range %q", part)
+		}
+	}
+	return nil
+}
+
+// CommandlineArgs converts the Resources into a list of strings that
+// may be passed to exec.Command as part of the args for docker run.
+func (r Resources) CommandlineArgs() []string {
+	var args []string
+
+	if r.Memory != 0 {
+		args = append(args, "--memory", r.Memory.String())
+	}
+
+	if r.MemorySwap != 0 {
+		args = append(args, "--memory-swap", r.MemorySwap.String())
+	}
+
+	if r.MemoryReservation != 0 {
+		args = append(args, "--memory-reservation", r.MemoryReservation.String())
+	}
+
+	if r.CPUShares != 0 {
+		args = append(args, "--cpu-shares", strconv.FormatInt(r.CPUShares, 10))
+	}
+
+	if r.CPUPeriod != 0 {
+		args = append(args, "--cpu-period", strconv.FormatInt(r.CPUPeriod.Microseconds(), 10))
+	}
+
+	if r.CPUQuota != 0 {
+		args = append(args, "--cpu-quota", strconv.FormatInt(r.CPUQuota.Microseconds(), 10))
+	}
+
+	if r.CPUs != 0 {
+		args = append(args, "--cpus", r.CPUs.String())
+	}
+
+	if r.CpusetCPUs != "" {
+		args = append(args, "--cpuset-cpus", r.CpusetCPUs)
+	}
+
+	if r.PidsLimit != 0 {
+		args = append(args, "--pids-limit", strconv.FormatInt(r.PidsLimit, 10))
+	}
+
+	if r.BlkioWeight != 0 {
+		args = append(args, "--blkio-weight", strconv.Itoa(int(r.BlkioWeight)))
+	}
+
+	if r.OOMKillDisable {
+		args = append(args, "--oom-kill-disable")
+	}
+
+	return args
+}
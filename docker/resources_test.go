@@ -0,0 +1,185 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker_test
+
+import (
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju-process-docker/docker"
+)
+
+var _ = gc.Suite(&resourcesSuite{})
+
+type resourcesSuite struct{}
+
+var parseByteSizeTests = []struct {
+	s    string
+	size docker.ByteSize
+	err  string
+}{
+	{s: "1048576", size: docker.MiB},
+	{s: "100b", size: 100},
+	{s: "4k", size: 4 * docker.KiB},
+	{s: "512m", size: 512 * docker.MiB},
+	{s: "512MB", size: 512 * docker.MiB},
+	{s: "1g", size: docker.GiB},
+	{s: "1.5g", size: 1536 * docker.MiB},
+	{s: " 2G ", size: 2 * docker.GiB},
+	{s: "-1", size: -1},
+	{s: "", err: `invalid size ""`},
+	{s: "lots", err: `invalid size "lots"`},
+	{s: "10t", err: `invalid size "10t"`},
+	{s: "-2m", err: `invalid size "-2m"`},
+	{s: "1e3", err: `invalid size "1e3"`},
+	{s: ".5g", err: `invalid size ".5g"`},
+	{s: "0.5", err: `invalid size "0.5": not a whole number of bytes`},
+	{s: "9999999999999g", err: `invalid size "9999999999999g": too large`},
+	{s: "8589934591g", size: 8589934591 * docker.GiB},
+}
+
+func (resourcesSuite) TestParseByteSize(c *gc.C) {
+	for i, test := range parseByteSizeTests {
+		c.Logf("test %d: %q", i, test.s)
+
+		size, err := docker.ParseByteSize(test.s)
+
+		if test.err != "" {
+			c.Check(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		if c.Check(err, jc.ErrorIsNil) {
+			c.Check(size, gc.Equals, test.size)
+		}
+	}
+}
+
+func (resourcesSuite) TestByteSizeString(c *gc.C) {
+	c.Check(docker.ByteSize(0).String(), gc.Equals, "0")
+	c.Check(docker.ByteSize(1000).String(), gc.Equals, "1000")
+	c.Check((4 * docker.KiB).String(), gc.Equals, "4k")
+	c.Check((1536 * docker.MiB).String(), gc.Equals, "1536m")
+	c.Check((2 * docker.GiB).String(), gc.Equals, "2g")
+	c.Check(docker.ByteSize(-1).String(), gc.Equals, "-1")
+}
+
+func (resourcesSuite) TestCPUsString(c *gc.C) {
+	c.Check(docker.CPUs(1).String(), gc.Equals, "1")
+	c.Check(docker.CPUs(0.25).String(), gc.Equals, "0.25")
+}
+
+var validateResourcesTests = []struct {
+	resources docker.Resources
+	err       string
+}{{
+	resources: docker.Resources{},
+}, {
+	resources: docker.Resources{
+		Memory:            512 * docker.MiB,
+		MemorySwap:        docker.GiB,
+		MemoryReservation: 256 * docker.MiB,
+		CPUShares:         512,
+		CPUPeriod:         100 * time.Millisecond,
+		CPUQuota:          50 * time.Millisecond,
+		CpusetCPUs:        "0-3,5",
+		PidsLimit:         100,
+		BlkioWeight:       500,
+		OOMKillDisable:    true,
+	},
+}, {
+	resources: docker.Resources{Memory: 512 * docker.MiB, MemorySwap: -1, CPUs: 1.5, PidsLimit: -1},
+}, {
+	resources: docker.Resources{Memory: -1},
+	err:       "invalid memory -1: must not be negative",
+}, {
+	resources: docker.Resources{Memory: docker.MiB},
+	err:       "invalid memory 1m: must be at least 6m",
+}, {
+	resources: docker.Resources{MemorySwap: docker.GiB},
+	err:       "invalid memory swap 1g: memory must be set too",
+}, {
+	resources: docker.Resources{Memory: docker.GiB, MemorySwap: 512 * docker.MiB},
+	err:       `invalid memory swap 512m: must be at least the memory \(1g\)`,
+}, {
+	resources: docker.Resources{MemoryReservation: -1},
+	err:       "invalid memory reservation -1: must not be negative",
+}, {
+	resources: docker.Resources{Memory: 512 * docker.MiB, MemoryReservation: docker.GiB},
+	err:       `invalid memory reservation 1g: must not be more than the memory \(512m\)`,
+}, {
+	resources: docker.Resources{CPUShares: -2},
+	err:       "invalid CPU shares -2: must not be negative",
+}, {
+	resources: docker.Resources{CPUPeriod: 2 * time.Second},
+	err:       "invalid CPU period 2s: must be between 1ms and 1s",
+}, {
+	resources: docker.Resources{CPUQuota: time.Microsecond},
+	err:       "invalid CPU quota 1µs: must be at least 1ms",
+}, {
+	resources: docker.Resources{CPUs: -0.5},
+	err:       "invalid CPUs -0.5: must be a non-negative number",
+}, {
+	resources: docker.Resources{CPUs: 2, CPUQuota: 50 * time.Millisecond},
+	err:       "invalid CPUs 2: can't be used with a CPU period or quota",
+}, {
+	resources: docker.Resources{CpusetCPUs: "0-x"},
+	err:       `invalid cpuset "0-x": bad CPU "x"`,
+}, {
+	resources: docker.Resources{CpusetCPUs: "3-1"},
+	err:       `invalid cpuset "3-1": bad range "3-1"`,
+}, {
+	resources: docker.Resources{PidsLimit: -2},
+	err:       "invalid pids limit -2: must be -1 or more",
+}, {
+	resources: docker.Resources{BlkioWeight: 5},
+	err:       "invalid blkio weight 5: must be between 10 and 1000",
+}}
+
+func (resourcesSuite) TestValidate(c *gc.C) {
+	for i, test := range validateResourcesTests {
+		c.Logf("test %d: %+v", i, test.resources)
+
+		err := test.resources.Validate()
+
+		if test.err == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, gc.ErrorMatches, test.err)
+		}
+	}
+}
+
+func (resourcesSuite) TestCommandlineArgs(c *gc.C) {
+	resources := docker.Resources{
+		Memory:            512 * docker.MiB,
+		MemorySwap:        -1,
+		MemoryReservation: 256 * docker.MiB,
+		CPUShares:         512,
+		CPUPeriod:         100 * time.Millisecond,
+		CPUQuota:          50 * time.Millisecond,
+		CpusetCPUs:        "0-3",
+		PidsLimit:         100,
+		BlkioWeight:       500,
+		OOMKillDisable:    true,
+	}
+
+	c.Check(resources.CommandlineArgs(), jc.DeepEquals, []string{
+		"--memory", "512m",
+		"--memory-swap", "-1",
+		"--memory-reservation", "256m",
+		"--cpu-shares", "512",
+		"--cpu-period", "100000",
+		"--cpu-quota", "50000",
+		"--cpuset-cpus", "0-3",
+		"--pids-limit", "100",
+		"--blkio-weight", "500",
+		"--oom-kill-disable",
+	})
+	c.Check(docker.Resources{CPUs: 0.5}.CommandlineArgs(), jc.DeepEquals, []string{
+		"--cpus", "0.5",
+	})
+	c.Check(docker.Resources{}.CommandlineArgs(), gc.HasLen, 0)
+}
@@ -0,0 +1,94 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RestartPolicyName identifies when docker restarts a container after
+// it exits.
+type RestartPolicyName string
+
+// These are the restart policies docker supports.
+const (
+	// RestartNo means the container is never restarted.
+	RestartNo RestartPolicyName = "no"
+	// RestartOnFailure means the container is restarted when it exits
+	// with a non-zero exit code.
+	RestartOnFailure RestartPolicyName = "on-failure"
+	// RestartAlways means the container is always restarted, including
+	// when the daemon starts.
+	RestartAlways RestartPolicyName = "always"
+	// RestartUnlessStopped is like RestartAlways, except that a
+	// container that was stopped is not restarted when the daemon
+	// starts.
+	RestartUnlessStopped RestartPolicyName = "unless-stopped"
+)
+
+// RestartPolicy describes when docker restarts a container after it
+// exits. The zero value means docker's default (RestartNo) is used.
+type RestartPolicy struct {
+	// Name identifies the policy.
+	Name RestartPolicyName
+	// MaxRetries is the most times the container is restarted, which
+	// is only allowed with RestartOnFailure. Zero means there is
+	// no limit.
+	MaxRetries int
+}
+
+// ParseRestartPolicy converts a restart policy in the form docker
+// accepts (e.g. "always", "on-failure:5") into a RestartPolicy.
+func ParseRestartPolicy(s string) (RestartPolicy, error) {
+	var policy RestartPolicy
+	parts := strings.SplitN(s, ":", 2)
+	policy.Name = RestartPolicyName(parts[0])
+	if len(parts) == 2 {
+		retries, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return RestartPolicy{}, fmt.Errorf("invalid restart policy %q: bad retry count", s)
+		}
+		policy.MaxRetries = retries
+	}
+	if err := policy.Validate(); err != nil {
+		return RestartPolicy{}, err
+	}
+	return policy, nil
+}
+
+// String returns the policy in the form docker accepts.
+func (rp RestartPolicy) String() string {
+	if rp.MaxRetries != 0 {
+		return fmt.Sprintf("%s:%d", rp.Name, rp.MaxRetries)
+	}
+	return string(rp.Name)
+}
+
+// Validate checks that the policy is one docker will accept.
+func (rp RestartPolicy) Validate() error {
+	if problem := rp.problem(); problem != nil {
+		return problem.Err
+	}
+	return nil
+}
+
+// problem returns the problem with the policy, if any, keyed by the
+// field at fault.
+func (rp RestartPolicy) problem() *FieldError {
+	switch rp.Name {
+	case "", RestartNo, RestartAlways, RestartUnlessStopped:
+		if rp.MaxRetries != 0 {
+			return &FieldError{Field: "MaxRetries", Err: fmt.Errorf("invalid restart policy %q: a max retry count is only allowed with %q", rp, RestartOnFailure)}
+		}
+	case RestartOnFailure:
+		if rp.MaxRetries < 0 {
+			return &FieldError{Field: "MaxRetries", Err: fmt.Errorf("invalid restart policy %q: max retry count must not be negative", rp)}
+		}
+	default:
+		return &FieldError{Field: "Name", Err: fmt.Errorf("invalid restart policy %q: unknown policy", rp)}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker_test
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju-process-docker/docker"
+)
+
+var _ = gc.Suite(&restartSuite{})
+
+type restartSuite struct{}
+
+var parseRestartPolicyTests = []struct {
+	s      string
+	policy docker.RestartPolicy
+	err    string
+}{{
+	s:      "no",
+	policy: docker.RestartPolicy{Name: docker.RestartNo},
+}, {
+	s:      "always",
+	policy: docker.RestartPolicy{Name: docker.RestartAlways},
+}, {
+	s:      "unless-stopped",
+	policy: docker.RestartPolicy{Name: docker.RestartUnlessStopped},
+}, {
+	s:      "on-failure",
+	policy: docker.RestartPolicy{Name: docker.RestartOnFailure},
+}, {
+	s:      "on-failure:5",
+	policy: docker.RestartPolicy{Name: docker.RestartOnFailure, MaxRetries: 5},
+}, {
+	s:   "on-failure:lots",
+	err: `invalid restart policy "on-failure:lots": bad retry count`,
+}, {
+	s:   "on-failure:-1",
+	err: `invalid restart policy "on-failure:-1": max retry count must not be negative`,
+}, {
+	s:   "always:3",
+	err: `invalid restart policy "always:3": a max retry count is only allowed with "on-failure"`,
+}, {
+	s:   "sometimes",
+	err: `invalid restart policy "sometimes": unknown policy`,
+}}
+
+func (restartSuite) TestParseRestartPolicy(c *gc.C) {
+	for i, test := range parseRestartPolicyTests {
+		c.Logf("test %d: %q", i, test.s)
+
+		policy, err := docker.ParseRestartPolicy(test.s)
+
+		if test.err != "" {
+			c.Check(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		if c.Check(err, jc.ErrorIsNil) {
+			c.Check(policy, jc.DeepEquals, test.policy)
+			c.Check(policy.String(), gc.Equals, test.s)
+		}
+	}
+}
+
+func (restartSuite) TestValidate(c *gc.C) {
+	c.Check(docker.RestartPolicy{}.Validate(), jc.ErrorIsNil)
+
+	err := docker.RestartPolicy{MaxRetries: 2}.Validate()
+	c.Check(err, gc.ErrorMatches, `invalid restart policy ":2": a max retry count is only allowed with "on-failure"`)
+}
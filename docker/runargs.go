@@ -0,0 +1,238 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/runconfig"
+)
+
+// RunArgs rebuilds the args that would run a container configured like
+// this one, e.g. to recreate it or to check it for drift. The
+// container's Config includes whatever it inherited from its image, so
+// if the image's config is given (it may be nil), settings that match
+// the image's defaults are left out. Secrets can't be told apart from
+// other env vars, and the actual host ports of ports docker picked are
+// not kept, so that the result runs an equivalent container. Nor are
+// the options docker doesn't report for mounts given in its long form
+// (bind propagation, volume nocopy, and tmpfs size and mode).
+func (info Info) RunArgs(image *runconfig.Config) (RunArgs, error) {
+	var args RunArgs
+	if info.ContainerJSONBase != nil {
+		args.Name = strings.TrimPrefix(info.Name, "/")
+		args.Resources = info.Resources()
+		args.RestartPolicy = info.RestartPolicy()
+		if info.HostConfig != nil {
+			args.Ports = portBindings(info.HostConfig)
+		}
+	}
+	if args.RestartPolicy.Name == RestartNo {
+		// This is docker's default, which it reports even when no
+		// policy was given.
+		args.RestartPolicy = RestartPolicy{}
+	}
+	if image == nil {
+		image = &runconfig.Config{}
+	}
+
+	if config := info.Config; config != nil {
+		args.Image = config.Image
+		if !reflect.DeepEqual(config.Entrypoint.Slice(), image.Entrypoint.Slice()) {
+			args.Entrypoint = config.Entrypoint.Slice()
+		}
+		// docker only inherits the image's command when the
+		// entrypoint is not overridden.
+		if args.Entrypoint != nil || !reflect.DeepEqual(config.Cmd.Slice(), image.Cmd.Slice()) {
+			args.Args = config.Cmd.Slice()
+		}
+		if config.WorkingDir != image.WorkingDir {
+			args.WorkDir = config.WorkingDir
+		}
+		if config.User != image.User {
+			args.User = config.User
+		}
+		args.Labels = labelsNotIn(config.Labels, image.Labels)
+		args.Env = envNotIn(config.Env, image.Env)
+	}
+
+	mounts, err := info.mounts(image)
+	if err != nil {
+		return RunArgs{}, err
+	}
+	args.Mounts = mounts
+	return args, nil
+}
+
+// labelsNotIn returns the labels that are not set to the same value in
+// the image's labels.
+func labelsNotIn(labels, imageLabels map[string]string) map[string]string {
+	var result map[string]string
+	for key, value := range labels {
+		if imageValue, ok := imageLabels[key]; ok && imageValue == value {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]string)
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// envNotIn returns the env vars (in NAME=VALUE form) that are not set
+// to the same value in the image's env, in order.
+func envNotIn(env, imageEnv []string) EnvList {
+	inherited := make(map[string]bool)
+	for _, ev := range imageEnv {
+		inherited[ev] = true
+	}
+	var result EnvList
+	for _, ev := range env {
+		if inherited[ev] {
+			continue
+		}
+		parts := strings.SplitN(ev, "=", 2)
+		envVar := EnvVar{Name: parts[0]}
+		if len(parts) == 2 {
+			envVar.Value = parts[1]
+		}
+		result = append(result, envVar)
+	}
+	return result
+}
+
+// portBindings returns the ports that were requested to be published,
+// with one result per port. Where docker was left to pick the host
+// port, External is zero.
+func portBindings(hc *runconfig.HostConfig) []PortAssignment {
+	var ports []PortAssignment
+	for port, bindings := range hc.PortBindings {
+		internal, err := strconv.Atoi(port.Port())
+		if err != nil {
+			continue
+		}
+		for _, binding := range bindings {
+			pa := PortAssignment{
+				HostIP:   binding.HostIP,
+				Internal: internal,
+				Protocol: port.Proto(),
+			}
+			if binding.HostPort != "" {
+				if pa.External, err = strconv.Atoi(binding.HostPort); err != nil {
+					continue
+				}
+			}
+			ports = append(ports, pa)
+		}
+	}
+	sortPorts(ports)
+	return ports
+}
+
+// sortPorts sorts the port mappings by internal port, protocol, host
+// address and then external port.
+func sortPorts(ports []PortAssignment) {
+	sort.Slice(ports, func(i, j int) bool {
+		a, b := ports[i], ports[j]
+		if a.Internal != b.Internal {
+			return a.Internal < b.Internal
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if a.HostIP != b.HostIP {
+			return a.HostIP < b.HostIP
+		}
+		return a.External < b.External
+	})
+}
+
+// mounts returns the container's mounts: its binds (including named
+// volumes), as they were given, followed by its other mounts, in order
+// of container path. Anonymous volumes declared by the image are left
+// out. Mounts given in docker's long form are rebuilt from what docker
+// reports of them, which does not include bind propagation, volume
+// nocopy, or the size and mode of a tmpfs, so those are not kept.
+func (info Info) mounts(image *runconfig.Config) ([]MountAssignment, error) {
+	var mounts []MountAssignment
+	bound := make(map[string]bool)
+	if info.ContainerJSONBase != nil && info.HostConfig != nil {
+		for _, bind := range info.HostConfig.Binds {
+			ma, err := ParseMountAssignment(bind)
+			if err != nil {
+				return nil, fmt.Errorf("can't rebuild mounts of container %s: %s", info.Name, err)
+			}
+			mounts = append(mounts, ma)
+			bound[path.Clean(ma.Internal)] = true
+		}
+	}
+
+	var volumes map[string]struct{}
+	if info.Config != nil {
+		volumes = info.Config.Volumes
+	}
+	var others []MountAssignment
+	for _, mp := range info.Mounts {
+		if bound[path.Clean(mp.Destination)] {
+			continue
+		}
+		if _, ok := volumes[mp.Destination]; ok {
+			if _, ok := image.Volumes[mp.Destination]; ok {
+				continue
+			}
+			others = append(others, MountAssignment{
+				Type:     MountVolume,
+				Internal: mp.Destination,
+				ReadOnly: !mp.RW,
+			})
+			continue
+		}
+		ma, err := mountFromPoint(mp)
+		if err != nil {
+			return nil, fmt.Errorf("can't rebuild mounts of container %s: %s", info.Name, err)
+		}
+		others = append(others, ma)
+	}
+	sort.Slice(others, func(i, j int) bool {
+		return others[i].Internal < others[j].Internal
+	})
+	return append(mounts, others...), nil
+}
+
+// anonymousVolumeName matches the names docker generates for anonymous
+// volumes.
+var anonymousVolumeName = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// mountFromPoint rebuilds a mount given in docker's long form from the
+// mount point docker reports for it. Only a volume has a name, and only
+// a bind or volume has a source.
+func mountFromPoint(mp types.MountPoint) (MountAssignment, error) {
+	ma := MountAssignment{
+		Internal: mp.Destination,
+		ReadOnly: !mp.RW,
+	}
+	switch {
+	case mp.Name != "":
+		ma.Type = MountVolume
+		if !anonymousVolumeName.MatchString(mp.Name) {
+			ma.External = mp.Name
+		}
+	case mp.Source != "":
+		ma.Type, ma.External = MountBind, mp.Source
+	default:
+		ma.Type = MountTmpfs
+	}
+	if err := ma.Validate(); err != nil {
+		return MountAssignment{}, err
+	}
+	return ma, nil
+}
@@ -0,0 +1,227 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker_test
+
+import (
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/nat"
+	"github.com/docker/docker/runconfig"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju-process-docker/docker"
+)
+
+var _ = gc.Suite(&runArgsSuite{})
+
+type runArgsSuite struct{}
+
+func (runArgsSuite) TestRunArgsNoImage(c *gc.C) {
+	info := docker.Info(*fakeInfo)
+
+	args, err := info.RunArgs(nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(args, jc.DeepEquals, docker.RunArgs{
+		Name:    "sad_perlman",
+		Image:   "docker/whalesay",
+		Args:    []string{"sleep", "30"},
+		WorkDir: "/cowsay",
+		Env: docker.EnvList{
+			{Name: "PATH", Value: "/usr/local/bin:/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+		},
+	})
+}
+
+func (runArgsSuite) TestRunArgsWithImage(c *gc.C) {
+	info := docker.Info(*fakeInfo)
+	image := &runconfig.Config{
+		Env: []string{
+			"PATH=/usr/local/bin:/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		},
+		Cmd:        runconfig.NewCommand("/bin/bash"),
+		WorkingDir: "/cowsay",
+	}
+
+	args, err := info.RunArgs(image)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(args, jc.DeepEquals, docker.RunArgs{
+		Name:  "sad_perlman",
+		Image: "docker/whalesay",
+		Args:  []string{"sleep", "30"},
+	})
+}
+
+func (runArgsSuite) TestRunArgsFull(c *gc.C) {
+	info := docker.Info(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			Name: "/spam",
+			HostConfig: &runconfig.HostConfig{
+				Binds: []string{
+					"/srv/data:/data:ro",
+					"cache:/cache",
+				},
+				Memory: 512 << 20,
+				PortBindings: nat.PortMap{
+					"80/tcp":  {{HostIP: "", HostPort: "8080"}},
+					"53/udp":  {{HostIP: "127.0.0.1", HostPort: "5353"}},
+					"443/tcp": {{HostIP: "", HostPort: ""}},
+				},
+				RestartPolicy: runconfig.RestartPolicy{
+					Name:              "on-failure",
+					MaximumRetryCount: 3,
+				},
+			},
+		},
+		Mounts: []types.MountPoint{
+			{Source: "/srv/data", Destination: "/data", Mode: "ro"},
+			{Name: "cache", Source: "/var/lib/docker/volumes/cache/_data", Destination: "/cache", RW: true},
+			{Name: "0123abcd", Source: "/var/lib/docker/volumes/0123abcd/_data", Destination: "/scratch", RW: true},
+			{Name: "4567cdef", Source: "/var/lib/docker/volumes/4567cdef/_data", Destination: "/var/lib/spam", RW: true},
+		},
+		Config: &runconfig.Config{
+			Image:      "my-spam",
+			Entrypoint: runconfig.NewEntrypoint("/bin/sh", "-c"),
+			Cmd:        runconfig.NewCommand("do something"),
+			User:       "1000",
+			Env:        []string{"LANG=C", "FOO=bar", "EMPTY="},
+			Labels: map[string]string{
+				"com.canonical.juju.unit": "spam/0",
+				"maintainer":              "someone",
+				"version":                 "2",
+			},
+			Volumes: map[string]struct{}{
+				"/data":         {},
+				"/cache":        {},
+				"/scratch":      {},
+				"/var/lib/spam": {},
+			},
+		},
+	})
+	image := &runconfig.Config{
+		Entrypoint: runconfig.NewEntrypoint("/bin/sh", "-c"),
+		Env:        []string{"LANG=C", "FOO=baz"},
+		Labels: map[string]string{
+			"maintainer": "someone",
+			"version":    "1",
+		},
+		Volumes: map[string]struct{}{
+			"/var/lib/spam": {},
+		},
+	}
+
+	args, err := info.RunArgs(image)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(args, jc.DeepEquals, docker.RunArgs{
+		Name:          "spam",
+		Image:         "my-spam",
+		Args:          []string{"do something"},
+		User:          "1000",
+		Resources:     docker.Resources{Memory: 512 * docker.MiB},
+		RestartPolicy: docker.RestartPolicy{Name: docker.RestartOnFailure, MaxRetries: 3},
+		Labels: map[string]string{
+			"com.canonical.juju.unit": "spam/0",
+			"version":                 "2",
+		},
+		Env: docker.EnvList{
+			{Name: "FOO", Value: "bar"},
+			{Name: "EMPTY", Value: ""},
+		},
+		Ports: []docker.PortAssignment{
+			{HostIP: "127.0.0.1", External: 5353, Internal: 53, Protocol: "udp"},
+			{External: 8080, Internal: 80, Protocol: "tcp"},
+			{Internal: 443, Protocol: "tcp"},
+		},
+		Mounts: []docker.MountAssignment{
+			{External: "/srv/data", Internal: "/data", Mode: "ro"},
+			{External: "cache", Internal: "/cache"},
+			{Type: docker.MountVolume, Internal: "/scratch"},
+		},
+	})
+	c.Check(args.Validate(), jc.ErrorIsNil)
+}
+
+func (runArgsSuite) TestRunArgsEntrypointOverridden(c *gc.C) {
+	info := docker.Info(types.ContainerJSON{
+		Config: &runconfig.Config{
+			Image:      "my-spam",
+			Entrypoint: runconfig.NewEntrypoint("/bin/true"),
+			Cmd:        runconfig.NewCommand("/bin/bash"),
+		},
+	})
+	image := &runconfig.Config{
+		Cmd: runconfig.NewCommand("/bin/bash"),
+	}
+
+	args, err := info.RunArgs(image)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(args.Entrypoint, jc.DeepEquals, []string{"/bin/true"})
+	c.Check(args.Args, jc.DeepEquals, []string{"/bin/bash"})
+}
+
+func (runArgsSuite) TestRunArgsBadBind(c *gc.C) {
+	info := docker.Info(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			Name: "/spam",
+			HostConfig: &runconfig.HostConfig{
+				Binds: []string{"/srv/data"},
+			},
+		},
+	})
+
+	_, err := info.RunArgs(nil)
+	c.Check(err, gc.ErrorMatches, `can't rebuild mounts of container /spam: invalid mount assignment "/srv/data": .*`)
+}
+
+func (runArgsSuite) TestRunArgsTypedMounts(c *gc.C) {
+	info := docker.Info(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			Name: "/spam",
+			HostConfig: &runconfig.HostConfig{
+				Binds: []string{"/srv/logs:/var/log", "/srv/src:/src:delegated"},
+			},
+		},
+		Mounts: []types.MountPoint{
+			{Source: "/srv/logs", Destination: "/var/log", Mode: "rw", RW: true},
+			{Source: "/srv/src", Destination: "/src", Mode: "delegated", RW: true},
+			{Source: "/srv/data", Destination: "/data"},
+			{Name: "cache", Driver: "local", Source: "/var/lib/docker/volumes/cache/_data", Destination: "/cache", RW: true},
+			{Name: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", Driver: "local", Source: "/var/lib/docker/volumes/0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef/_data", Destination: "/scratch", RW: true},
+			{Destination: "/run", RW: true},
+		},
+		Config: &runconfig.Config{
+			Image: "my-spam",
+		},
+	})
+
+	args, err := info.RunArgs(nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(args.Mounts, jc.DeepEquals, []docker.MountAssignment{
+		{External: "/srv/logs", Internal: "/var/log"},
+		{External: "/srv/src", Internal: "/src", Mode: "delegated"},
+		{Type: docker.MountVolume, External: "cache", Internal: "/cache"},
+		{Type: docker.MountBind, External: "/srv/data", Internal: "/data", ReadOnly: true},
+		{Type: docker.MountTmpfs, Internal: "/run"},
+		{Type: docker.MountVolume, Internal: "/scratch"},
+	})
+	c.Check(args.Validate(), jc.ErrorIsNil)
+}
+
+func (runArgsSuite) TestRunArgsBadMountPoint(c *gc.C) {
+	info := docker.Info(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			Name: "/spam",
+		},
+		Mounts: []types.MountPoint{
+			{Source: "/srv/data", Destination: "data"},
+		},
+	})
+
+	_, err := info.RunArgs(nil)
+	c.Check(err, gc.ErrorMatches, `can't rebuild mounts of container /spam: invalid mount assignment .*: internal path "data" is not absolute`)
+}
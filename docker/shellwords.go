@@ -0,0 +1,96 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SplitCommand splits the command line into its arguments, following
+// the quoting rules of a POSIX shell. Words are separated by unquoted
+// whitespace. Within single quotes every character is literal, while
+// within double quotes a backslash escapes only $, `, ", \ and newline.
+// Elsewhere a backslash escapes the next character. No other shell
+// expansion is done.
+func SplitCommand(command string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+
+		case r == '\\':
+			i++
+			if i == len(runes) {
+				return nil, fmt.Errorf("invalid command %q: trailing backslash", command)
+			}
+			if runes[i] != '\n' {
+				// An escaped newline continues the line, but does not
+				// start a word.
+				inWord = true
+				word.WriteRune(runes[i])
+			}
+
+		case r == '\'':
+			inWord = true
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid command %q: unterminated single quote", command)
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+
+		case r == '"':
+			inWord = true
+			closed := false
+			for i++; i < len(runes); i++ {
+				r := runes[i]
+				if r == '"' {
+					closed = true
+					break
+				}
+				if r == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[i+1]) {
+					i++
+					if runes[i] != '\n' {
+						word.WriteRune(runes[i])
+					}
+					continue
+				}
+				word.WriteRune(r)
+			}
+			if !closed {
+				return nil, fmt.Errorf("invalid command %q: unterminated double quote", command)
+			}
+
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
+
+// indexRune returns the index of the first instance of r in runes at
+// or after start, or -1 if there is none.
+func indexRune(runes []rune, start int, r rune) int {
+	for i := start; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
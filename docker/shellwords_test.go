@@ -0,0 +1,100 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker_test
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju-process-docker/docker"
+)
+
+var _ = gc.Suite(&shellwordsSuite{})
+
+type shellwordsSuite struct{}
+
+var splitCommandTests = []struct {
+	command string
+	args    []string
+	err     string
+}{{
+	command: "",
+	args:    nil,
+}, {
+	command: "   \t\n ",
+	args:    nil,
+}, {
+	command: "do something",
+	args:    []string{"do", "something"},
+}, {
+	command: "  do \t something\n",
+	args:    []string{"do", "something"},
+}, {
+	command: `sh -c "echo hello world"`,
+	args:    []string{"sh", "-c", "echo hello world"},
+}, {
+	command: `sh -c 'echo "hello world"'`,
+	args:    []string{"sh", "-c", `echo "hello world"`},
+}, {
+	command: `echo 'it'\''s'`,
+	args:    []string{"echo", "it's"},
+}, {
+	command: `echo 'no \escapes $here'`,
+	args:    []string{"echo", `no \escapes $here`},
+}, {
+	command: `echo "a \"quoted\" \$word \\ \n"`,
+	args:    []string{"echo", `a "quoted" $word \ \n`},
+}, {
+	command: `echo hello\ world \"x\"`,
+	args:    []string{"echo", "hello world", `"x"`},
+}, {
+	command: `echo '' ""`,
+	args:    []string{"echo", "", ""},
+}, {
+	command: `echo pre"mid"'post'`,
+	args:    []string{"echo", "premidpost"},
+}, {
+	command: "echo one\\\ntwo",
+	args:    []string{"echo", "onetwo"},
+}, {
+	command: "a \\\n b",
+	args:    []string{"a", "b"},
+}, {
+	command: "a \\\n",
+	args:    []string{"a"},
+}, {
+	command: "a \\ b",
+	args:    []string{"a", " b"},
+}, {
+	command: `echo "ünïcödé wörds"`,
+	args:    []string{"echo", "ünïcödé wörds"},
+}, {
+	command: `echo 'unbalanced`,
+	err:     `invalid command "echo 'unbalanced": unterminated single quote`,
+}, {
+	command: `echo "unbalanced`,
+	err:     `invalid command "echo \\"unbalanced": unterminated double quote`,
+}, {
+	command: `echo "escaped\"`,
+	err:     `invalid command "echo \\"escaped\\\\\\"": unterminated double quote`,
+}, {
+	command: `echo trailing\`,
+	err:     `invalid command "echo trailing\\\\": trailing backslash`,
+}}
+
+func (shellwordsSuite) TestSplitCommand(c *gc.C) {
+	for i, test := range splitCommandTests {
+		c.Logf("test %d: %s", i, test.command)
+
+		args, err := docker.SplitCommand(test.command)
+
+		if test.err != "" {
+			c.Check(err, gc.ErrorMatches, test.err)
+			continue
+		}
+		if c.Check(err, jc.ErrorIsNil) {
+			c.Check(args, jc.DeepEquals, test.args)
+		}
+	}
+}
@@ -5,24 +5,121 @@ package docker
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"io"
 	"os/exec"
+	"strconv"
+	"time"
 
 	"github.com/juju/deputy"
 )
 
 const executable = "docker"
 
-var execCommand = exec.Command
+var execCommand = exec.CommandContext
 
-func runDocker(command string, args ...string) ([]byte, error) {
+func runDocker(ctx context.Context, command string, args ...string) ([]byte, error) {
 	d := deputy.Deputy{
 		Errors: deputy.FromStderr,
 	}
-	cmd := execCommand(executable, append([]string{command}, args...)...)
+	cmd := execCommand(ctx, executable, append([]string{command}, args...)...)
 	out := &bytes.Buffer{}
 	cmd.Stdout = out
 	if err := d.Run(cmd); err != nil {
-		return nil, err
+		return nil, commandError(ctx, command, err)
 	}
 	return out.Bytes(), nil
 }
+
+// Streams holds the standard I/O streams to attach to a docker command.
+// Any of them may be nil.
+type Streams struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// maxErrorOutput is the most stderr output that is kept for reporting
+// a failed docker command.
+const maxErrorOutput = 4096
+
+func streamDocker(ctx context.Context, streams Streams, command string, args ...string) error {
+	cmd := execCommand(ctx, executable, append([]string{command}, args...)...)
+	cmd.Stdin = streams.Stdin
+	cmd.Stdout = streams.Stdout
+	errOut := &prefixBuffer{max: maxErrorOutput}
+	cmd.Stderr = errOut
+	if streams.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(streams.Stderr, errOut)
+	}
+	if err := cmd.Run(); err != nil {
+		if msg := bytes.TrimSpace(errOut.Bytes()); len(msg) > 0 {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return commandError(ctx, command, err)
+	}
+	return nil
+}
+
+// commandError returns the error to report for the docker command,
+// which failed with err. The command is killed when the context is
+// done, in which case its exit status tells us nothing useful.
+func commandError(ctx context.Context, command string, err error) error {
+	if ctx.Err() != nil {
+		return contextError("docker "+command, ctx.Err())
+	}
+	return err
+}
+
+// prefixBuffer is an io.Writer that keeps only the first max bytes
+// written to it.
+type prefixBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+// Write implements io.Writer. It never fails, even once the buffer
+// is full.
+func (b *prefixBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// cancelReader is an io.ReadCloser that also cancels the operation
+// producing its data when it is closed.
+type cancelReader struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer.
+func (r *cancelReader) Close() error {
+	r.cancel()
+	return r.ReadCloser.Close()
+}
+
+// contextError returns an error reporting that the operation was
+// abandoned because of the given context error.
+func contextError(op string, err error) error {
+	if err == context.DeadlineExceeded {
+		return fmt.Errorf("%s timed out: %w", op, err)
+	}
+	return fmt.Errorf("%s cancelled: %w", op, err)
+}
+
+// formatSeconds converts the duration into the whole number of seconds
+// that docker expects, rounding up so that any grace period is honoured.
+func formatSeconds(d time.Duration) string {
+	secs := (d + time.Second - 1) / time.Second
+	if secs < 0 {
+		secs = 0
+	}
+	return strconv.FormatInt(int64(secs), 10)
+}
@@ -4,10 +4,14 @@
 package docker
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"testing"
+	"time"
 
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
@@ -20,9 +24,9 @@ type utilSuite struct{}
 func (utilSuite) TestRunDocker(c *gc.C) {
 	calls := []execCommandCall{{}}
 	execCommand = fakeExecCommand(calls)
-	defer func() { execCommand = exec.Command }()
+	defer func() { execCommand = exec.CommandContext }()
 
-	out, err := runDocker("inspect", "sad_perlman")
+	out, err := runDocker(context.Background(), "inspect", "sad_perlman")
 	c.Assert(err, jc.ErrorIsNil)
 
 	c.Check(string(out), gc.Equals, `ran []string{"docker", "inspect", "sad_perlman"}`)
@@ -30,32 +34,124 @@ func (utilSuite) TestRunDocker(c *gc.C) {
 	c.Check(calls[0].argsIn, jc.DeepEquals, []string{"inspect", "sad_perlman"})
 }
 
+func (utilSuite) TestRunDockerTimeout(c *gc.C) {
+	calls := []execCommandCall{{hang: true}}
+	execCommand = fakeExecCommand(calls)
+	defer func() { execCommand = exec.CommandContext }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := runDocker(ctx, "stop", "sad_perlman")
+
+	c.Check(err, gc.ErrorMatches, "docker stop timed out: context deadline exceeded")
+	c.Check(errors.Is(err, context.DeadlineExceeded), jc.IsTrue)
+}
+
+func (utilSuite) TestRunDockerCancelled(c *gc.C) {
+	calls := []execCommandCall{{hang: true}}
+	execCommand = fakeExecCommand(calls)
+	defer func() { execCommand = exec.CommandContext }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, err := runDocker(ctx, "inspect", "sad_perlman")
+
+	c.Check(err, gc.ErrorMatches, "docker inspect cancelled: context canceled")
+	c.Check(errors.Is(err, context.Canceled), jc.IsTrue)
+}
+
+func (utilSuite) TestStreamDocker(c *gc.C) {
+	calls := []execCommandCall{{}}
+	execCommand = fakeExecCommand(calls)
+	defer func() { execCommand = exec.CommandContext }()
+
+	var stdout bytes.Buffer
+	err := streamDocker(context.Background(), Streams{Stdout: &stdout}, "logs", "sad_perlman")
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(stdout.String(), gc.Equals, `ran []string{"docker", "logs", "sad_perlman"}`)
+	c.Check(calls[0].argsIn, jc.DeepEquals, []string{"logs", "sad_perlman"})
+}
+
+func (utilSuite) TestStreamDockerFailed(c *gc.C) {
+	calls := []execCommandCall{{fail: true}}
+	execCommand = fakeExecCommand(calls)
+	defer func() { execCommand = exec.CommandContext }()
+
+	var stderr bytes.Buffer
+	err := streamDocker(context.Background(), Streams{Stderr: &stderr}, "logs", "sad_perlman")
+
+	c.Check(err, gc.ErrorMatches, "exit status 1: command failed!")
+	c.Check(stderr.String(), gc.Equals, "command failed!\n")
+}
+
+func (utilSuite) TestStreamDockerCancelled(c *gc.C) {
+	calls := []execCommandCall{{hang: true}}
+	execCommand = fakeExecCommand(calls)
+	defer func() { execCommand = exec.CommandContext }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	err := streamDocker(ctx, Streams{}, "logs", "--follow", "sad_perlman")
+
+	c.Check(err, gc.ErrorMatches, "docker logs cancelled: context canceled")
+}
+
+func (utilSuite) TestPrefixBuffer(c *gc.C) {
+	buf := &prefixBuffer{max: 5}
+
+	for _, s := range []string{"abc", "def", "ghi"} {
+		n, err := buf.Write([]byte(s))
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(n, gc.Equals, 3)
+	}
+
+	c.Check(buf.String(), gc.Equals, "abcde")
+}
+
+func (utilSuite) TestFormatSeconds(c *gc.C) {
+	for d, expected := range map[time.Duration]string{
+		0:                  "0",
+		-time.Second:       "0",
+		time.Millisecond:   "1",
+		10 * time.Second:   "10",
+		10*time.Second + 1: "11",
+		2 * time.Minute:    "120",
+	} {
+		c.Check(formatSeconds(d), gc.Equals, expected, gc.Commentf("%v", d))
+	}
+}
+
 type execCommandCall struct {
 	fail bool
+	hang bool
 
 	nameIn string
 	argsIn []string
 }
 
-// fakeExecCommand returns a func that replaces the normal exec.Command
-// call to produce executables. It returns a command that calls this
+// fakeExecCommand returns a func that replaces the normal
+// exec.CommandContext call to produce executables. It returns a command that calls this
 // test executable, telling it to run our TestExecHelper test.  The
 // original command and arguments are passed as arguments to the
 // testhelper after a "--" argument.
-func fakeExecCommand(calls []execCommandCall) func(string, ...string) *exec.Cmd {
+func fakeExecCommand(calls []execCommandCall) func(context.Context, string, ...string) *exec.Cmd {
 	index := 0
-	return func(name string, args ...string) *exec.Cmd {
+	return func(ctx context.Context, name string, args ...string) *exec.Cmd {
 		calls[index].nameIn = name
 		calls[index].argsIn = args
 		call := calls[index]
 		index += 1
 
 		args = append([]string{"-test.run=TestExecHelper", "--", name}, args...)
-		cmd := exec.Command(os.Args[0], args...)
+		cmd := exec.CommandContext(ctx, os.Args[0], args...)
 		cmd.Env = []string{"GO_WANT_HELPER_PROCESS=1"}
 		if call.fail {
 			cmd.Env = append(cmd.Env, "GO_HELPER_PROCESS_ERROR=1")
 		}
+		if call.hang {
+			cmd.Env = append(cmd.Env, "GO_HELPER_PROCESS_HANG=1")
+		}
 		return cmd
 	}
 }
@@ -67,6 +163,10 @@ func TestExecHelper(*testing.T) {
 		return
 	}
 	args := getTestArgs()
+	if os.Getenv("GO_HELPER_PROCESS_HANG") == "1" {
+		// Wait to be killed.
+		time.Sleep(time.Minute)
+	}
 	shouldErr := os.Getenv("GO_HELPER_PROCESS_ERROR") == "1"
 	if shouldErr {
 		defer os.Exit(1)
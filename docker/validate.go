@@ -0,0 +1,192 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FieldError is a problem with a single field of a set of args.
+type FieldError struct {
+	// Field is the path to the field (e.g. "Ports[1]").
+	Field string
+	// Err describes the problem.
+	Err error
+}
+
+// Error implements error.
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+// ValidationError lists every problem found with a set of args.
+type ValidationError struct {
+	// Problems holds the problems, in the order of the fields they
+	// affect.
+	Problems []*FieldError
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		msgs[i] = problem.Error()
+	}
+	return "invalid run args: " + strings.Join(msgs, "; ")
+}
+
+// add records a problem with the field, if err is not nil.
+func (e *ValidationError) add(field string, err error) {
+	if err != nil {
+		e.Problems = append(e.Problems, &FieldError{Field: field, Err: err})
+	}
+}
+
+// addf records a problem with the field.
+func (e *ValidationError) addf(field, format string, args ...interface{}) {
+	e.add(field, fmt.Errorf(format, args...))
+}
+
+// Validate checks that docker will accept the args, before it is asked
+// to run anything. If not, the error is a *ValidationError that lists
+// every problem found.
+func (ra RunArgs) Validate() error {
+	verr := &ValidationError{}
+
+	if ra.Name != "" && !validContainerName.MatchString(ra.Name) {
+		verr.addf("Name", "invalid container name %q", ra.Name)
+	}
+	if ra.Image == "" {
+		verr.addf("Image", "must not be empty")
+	}
+	if _, err := ra.command(); err != nil {
+		verr.add("Command", err)
+	}
+	if len(ra.Entrypoint) > 0 && ra.Entrypoint[0] == "" {
+		verr.addf("Entrypoint[0]", "must not be empty")
+	}
+	for _, problem := range ra.Resources.problems() {
+		verr.add("Resources."+problem.Field, problem.Err)
+	}
+	if problem := ra.RestartPolicy.problem(); problem != nil {
+		verr.add("RestartPolicy."+problem.Field, problem.Err)
+	}
+
+	for _, key := range sortedKeys(ra.Labels) {
+		switch {
+		case key == "":
+			verr.addf(fmt.Sprintf("Labels[%q]", key), "key must not be empty")
+		case strings.Contains(key, "="):
+			// docker splits a label at the first "=".
+			verr.addf(fmt.Sprintf("Labels[%q]", key), "key must not contain \"=\"")
+		}
+	}
+
+	ra.validateEnv(verr)
+	validatePorts(verr, ra.Ports)
+	validateMounts(verr, ra.Mounts)
+
+	if len(verr.Problems) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// validContainerName matches the names docker allows for a container,
+// which are restricted in the same way as volume names, except that
+// they may start with "/" (as docker reports them).
+var validContainerName = regexp.MustCompile(`^/?[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// validateEnv records any problems with the environment variables.
+func (ra RunArgs) validateEnv(verr *ValidationError) {
+	useEnvFile := ra.useEnvFile()
+	check := func(field, name, value string) {
+		if err := validateEnvName(name); err != nil {
+			verr.add(field, err)
+		}
+		// docker reads an env file a line at a time, and has no way
+		// to escape a newline.
+		if useEnvFile && strings.ContainsAny(value, "\r\n") {
+			verr.addf(field, "value contains a newline, which can't be written to an env file")
+		}
+	}
+	for _, name := range sortedKeys(ra.EnvVars) {
+		check(fmt.Sprintf("EnvVars[%q]", name), name, ra.EnvVars[name])
+	}
+	for i, ev := range ra.Env {
+		check(fmt.Sprintf("Env[%d]", i), ev.Name, ev.Value)
+	}
+}
+
+// validatePorts records any problems with the port mappings, including
+// host ports that are used by more than one of them.
+func validatePorts(verr *ValidationError, ports []PortAssignment) {
+	type hostPort struct {
+		port     int
+		protocol string
+	}
+	type use struct {
+		index  int
+		hostIP string
+	}
+	used := make(map[hostPort][]use)
+	for i, pa := range ports {
+		field := fmt.Sprintf("Ports[%d]", i)
+		if err := pa.Validate(); err != nil {
+			verr.add(field, err)
+			continue
+		}
+		if pa.External == 0 {
+			// docker picks a free port.
+			continue
+		}
+		reported := false
+		for _, p := range pa.expand() {
+			key := hostPort{port: p.External, protocol: p.protocol()}
+			for _, u := range used[key] {
+				// An empty host IP binds every address, so it
+				// overlaps with any other.
+				if reported || (u.hostIP != "" && p.HostIP != "" && u.hostIP != p.HostIP) {
+					continue
+				}
+				verr.addf(field, "host port %d/%s is already used by Ports[%d]", p.External, key.protocol, u.index)
+				reported = true
+			}
+			used[key] = append(used[key], use{index: i, hostIP: p.HostIP})
+		}
+	}
+}
+
+// validateMounts records any problems with the mounts, including
+// container paths that are mounted more than once.
+func validateMounts(verr *ValidationError, mounts []MountAssignment) {
+	used := make(map[string]int)
+	for i, ma := range mounts {
+		field := fmt.Sprintf("Mounts[%d]", i)
+		if err := ma.Validate(); err != nil {
+			verr.add(field, err)
+			continue
+		}
+		target := path.Clean(ma.Internal)
+		if j, ok := used[target]; ok {
+			verr.addf(field, "container path %q is already used by Mounts[%d]", ma.Internal, j)
+			continue
+		}
+		used[target] = i
+	}
+}
+
+// sortedKeys returns the keys of the map in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,184 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker_test
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju-process-docker/docker"
+)
+
+var _ = gc.Suite(&validateSuite{})
+
+type validateSuite struct{}
+
+var validateRunArgsTests = []struct {
+	about    string
+	args     docker.RunArgs
+	problems []string
+}{{
+	about: "minimal",
+	args:  docker.RunArgs{Image: "my-spam"},
+}, {
+	about: "everything valid",
+	args: docker.RunArgs{
+		Name:       "spam",
+		Image:      "my-spam",
+		Args:       []string{"do", "something"},
+		Entrypoint: []string{"/bin/sh", "-c"},
+		Labels:     map[string]string{"a": "b"},
+		EnvVars:    map[string]string{"FOO": "bar"},
+		Env:        docker.EnvList{{Name: "KEY", Value: "line 1\nline 2"}},
+		Ports: []docker.PortAssignment{
+			{External: 8080, Internal: 80},
+			{External: 8080, Internal: 80, Protocol: "udp"},
+			{HostIP: "127.0.0.1", External: 9000, Internal: 90},
+			{HostIP: "127.0.0.2", External: 9000, Internal: 91},
+			{Internal: 443},
+			{Internal: 443},
+		},
+		Mounts: []docker.MountAssignment{
+			{External: "/srv/data", Internal: "/data"},
+			{External: "cache", Internal: "/cache"},
+		},
+	},
+}, {
+	about: "name with a leading slash",
+	args: docker.RunArgs{
+		Name:  "/spam",
+		Image: "my-spam",
+	},
+}, {
+	about: "empty image",
+	args:  docker.RunArgs{},
+	problems: []string{
+		`Image: must not be empty`,
+	},
+}, {
+	about: "many problems",
+	args: docker.RunArgs{
+		Name:          "//spam",
+		Command:       "do something",
+		Args:          []string{"do", "something"},
+		Entrypoint:    []string{""},
+		Resources:     docker.Resources{Memory: -1, CPUShares: -2, BlkioWeight: 1},
+		RestartPolicy: docker.RestartPolicy{Name: "sometimes"},
+		Labels:        map[string]string{"": "b", "a=b": "c"},
+		EnvVars:       map[string]string{"A=B": "c"},
+		Env:           docker.EnvList{{Name: ""}},
+		Ports: []docker.PortAssignment{
+			{External: 8080, Internal: 80, Protocol: "icmp"},
+		},
+		Mounts: []docker.MountAssignment{
+			{External: "./data", Internal: "/data"},
+		},
+	},
+	problems: []string{
+		`Name: invalid container name "//spam"`,
+		`Image: must not be empty`,
+		`Command: only one of Command and Args may be set`,
+		`Entrypoint[0]: must not be empty`,
+		`Resources.Memory: invalid memory -1: must not be negative`,
+		`Resources.CPUShares: invalid CPU shares -2: must not be negative`,
+		`Resources.BlkioWeight: invalid blkio weight 1: must be between 10 and 1000`,
+		`RestartPolicy.Name: invalid restart policy "sometimes": unknown policy`,
+		`Labels[""]: key must not be empty`,
+		`Labels["a=b"]: key must not contain "="`,
+		`EnvVars["A=B"]: invalid env var name "A=B": must not contain "="`,
+		`Env[0]: invalid env var name "": must not be empty`,
+		`Ports[0]: invalid port assignment "8080:80/icmp": unknown protocol "icmp"`,
+		`Mounts[0]: invalid mount assignment "./data:/data": external "./data" is neither an absolute path nor a volume name`,
+	},
+}, {
+	about: "newline in env file",
+	args: docker.RunArgs{
+		Image:   "my-spam",
+		EnvVars: map[string]string{"FOO": "bar\nbaz"},
+		EnvFile: true,
+	},
+	problems: []string{
+		`EnvVars["FOO"]: value contains a newline, which can't be written to an env file`,
+	},
+}, {
+	about: "duplicate host ports",
+	args: docker.RunArgs{
+		Image: "my-spam",
+		Ports: []docker.PortAssignment{
+			{External: 8080, Internal: 80},
+			{External: 8080, Internal: 81, Protocol: "tcp"},
+			{HostIP: "127.0.0.1", External: 8079, Internal: 82, Count: 3},
+			{HostIP: "127.0.0.1", External: 9000, Internal: 90},
+			{HostIP: "127.0.0.1", External: 9000, Internal: 91},
+		},
+	},
+	problems: []string{
+		`Ports[1]: host port 8080/tcp is already used by Ports[0]`,
+		`Ports[2]: host port 8080/tcp is already used by Ports[0]`,
+		`Ports[4]: host port 9000/tcp is already used by Ports[3]`,
+	},
+}, {
+	about: "duplicate container paths",
+	args: docker.RunArgs{
+		Image: "my-spam",
+		Mounts: []docker.MountAssignment{
+			{External: "/srv/data", Internal: "/data"},
+			{Type: docker.MountVolume, Internal: "/data/"},
+			{Type: docker.MountTmpfs, Internal: "/run"},
+		},
+	},
+	problems: []string{
+		`Mounts[1]: container path "/data/" is already used by Mounts[0]`,
+	},
+}}
+
+func (validateSuite) TestValidateRunArgs(c *gc.C) {
+	for i, test := range validateRunArgsTests {
+		c.Logf("test %d: %s", i, test.about)
+
+		err := test.args.Validate()
+		if len(test.problems) == 0 {
+			c.Check(err, jc.ErrorIsNil)
+			continue
+		}
+		c.Assert(err, gc.FitsTypeOf, &docker.ValidationError{})
+		var problems []string
+		for _, problem := range err.(*docker.ValidationError).Problems {
+			problems = append(problems, problem.Error())
+		}
+		c.Check(problems, jc.DeepEquals, test.problems)
+	}
+}
+
+func (validateSuite) TestValidationErrorMessage(c *gc.C) {
+	err := docker.RunArgs{
+		Image: "my-spam",
+		Mounts: []docker.MountAssignment{
+			{External: "/srv/data", Internal: "data"},
+			{External: "/srv/data", Internal: "/"},
+		},
+	}.Validate()
+
+	c.Check(err, gc.ErrorMatches, `invalid run args: `+
+		`Mounts\[0\]: invalid mount assignment "/srv/data:data": internal path "data" is not absolute; `+
+		`Mounts\[1\]: invalid mount assignment "/srv/data:/": can't mount over the container's root`)
+	problem := err.(*docker.ValidationError).Problems[1]
+	c.Check(problem.Field, gc.Equals, "Mounts[1]")
+}
+
+func (validateSuite) TestRunValidates(c *gc.C) {
+	client, fake := newClient("eggs")
+
+	_, err := client.Run(docker.RunArgs{
+		Image: "my-spam",
+		Ports: []docker.PortAssignment{
+			{External: 8080, Internal: 80},
+			{External: 8080, Internal: 81},
+		},
+	})
+
+	c.Check(err, gc.ErrorMatches, `invalid run args: Ports\[1\]: host port 8080/tcp is already used by Ports\[0\]`)
+	c.Check(err, gc.FitsTypeOf, &docker.ValidationError{})
+	c.Check(fake.index, gc.Equals, 0)
+}
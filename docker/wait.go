@@ -0,0 +1,124 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// pollInterval is how often WaitUntil checks the container's state.
+var pollInterval = time.Second
+
+// ExitStatus describes how a container's main process exited.
+type ExitStatus struct {
+	// ExitCode is the exit code of the process.
+	ExitCode int
+	// OOMKilled indicates that the process was killed for running out
+	// of memory.
+	OOMKilled bool
+	// FinishedAt is when the process exited. It is zero if that is not
+	// known, e.g. because the container has since been removed.
+	FinishedAt time.Time
+}
+
+// newExitStatus returns the ExitStatus for the exited container, or
+// just for the exit code if the container is gone.
+func newExitStatus(exitCode int, info *Info) (*ExitStatus, error) {
+	status := &ExitStatus{
+		ExitCode: exitCode,
+	}
+	if info == nil {
+		return status, nil
+	}
+
+	status.OOMKilled = info.State.OOMKilled
+	finishedAt, err := time.Parse(time.RFC3339Nano, info.State.FinishedAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid finish time for container %s: %s", info.ID, err)
+	}
+	if !finishedAt.IsZero() {
+		status.FinishedAt = finishedAt
+	}
+	return status, nil
+}
+
+// WaitCondition identifies a container state that WaitUntil can wait for.
+type WaitCondition string
+
+// These are the conditions that WaitUntil can wait for.
+const (
+	// ConditionRunning is met once the container is running.
+	ConditionRunning WaitCondition = "running"
+	// ConditionHealthy is met once the container's health check
+	// passes. It is an error to wait for a running container without
+	// a health check to become healthy.
+	ConditionHealthy WaitCondition = "healthy"
+	// ConditionRemoved is met once the container no longer exists.
+	ConditionRemoved WaitCondition = "removed"
+)
+
+// containerStatus holds the parts of a container's state that are
+// needed to check a WaitCondition. It is decoded directly from the
+// docker inspect output, since Info does not include health.
+type containerStatus struct {
+	Running bool
+	Health  *struct {
+		Status string
+	}
+}
+
+// met reports whether the condition is met by the status (or error)
+// reported for the identified container.
+func (cond WaitCondition) met(id string, status *containerStatus, err error) (bool, error) {
+	if cond == ConditionRemoved {
+		if IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch cond {
+	case ConditionRunning:
+		return status.Running, nil
+	case ConditionHealthy:
+		// Health is only reported once the container has started.
+		if status.Health == nil {
+			if !status.Running {
+				return false, nil
+			}
+			return false, fmt.Errorf("container %s has no health check", id)
+		}
+		return status.Health.Status == "healthy", nil
+	}
+	return false, fmt.Errorf("unknown wait condition %q", cond)
+}
+
+// waitUntil polls the container's status until the condition is met,
+// or the context is done.
+func waitUntil(ctx context.Context, id string, cond WaitCondition, probe func(context.Context) (*containerStatus, error)) error {
+	for {
+		status, err := probe(ctx)
+		// A probe cut short by the context tells us nothing.
+		if ctx.Err() == nil {
+			met, err := cond.met(id, status, err)
+			if err != nil {
+				return err
+			}
+			if met {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return contextError(fmt.Sprintf("waiting for container %s to be %s", id, cond), ctx.Err())
+		case <-time.After(pollInterval):
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2015 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package docker_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju-process-docker/docker"
+)
+
+var _ = gc.Suite(&waitSuite{})
+
+type waitSuite struct {
+	testing.CleanupSuite
+}
+
+func (s *waitSuite) SetUpTest(c *gc.C) {
+	s.CleanupSuite.SetUpTest(c)
+	s.PatchValue(docker.PollInterval, time.Millisecond)
+}
+
+const (
+	stateCreated   = `{"Running":false}`
+	stateRunning   = `{"Running":true}`
+	stateStarting  = `{"Running":true,"Health":{"Status":"starting"}}`
+	stateUnhealthy = `{"Running":true,"Health":{"Status":"unhealthy"}}`
+	stateHealthy   = `{"Running":true,"Health":{"Status":"healthy"}}`
+)
+
+func (s *waitSuite) TestWaitUntilRunning(c *gc.C) {
+	client, fake := newClient(stateCreated, stateCreated, stateRunning)
+
+	err := client.WaitUntil(context.Background(), "sad_perlman", docker.ConditionRunning)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.index, gc.Equals, 3)
+	for _, call := range fake.calls {
+		c.Check(call.commandIn, gc.Equals, "inspect")
+		c.Check(call.argsIn, jc.DeepEquals, []string{
+			"--format", "{{json .State}}",
+			"sad_perlman",
+		})
+	}
+}
+
+func (s *waitSuite) TestWaitUntilHealthy(c *gc.C) {
+	client, fake := newClient(stateCreated, stateStarting, stateUnhealthy, stateHealthy)
+
+	err := client.WaitUntil(context.Background(), "sad_perlman", docker.ConditionHealthy)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.index, gc.Equals, 4)
+}
+
+func (s *waitSuite) TestWaitUntilHealthyNoHealthCheck(c *gc.C) {
+	client, _ := newClient(stateRunning)
+
+	err := client.WaitUntil(context.Background(), "sad_perlman", docker.ConditionHealthy)
+
+	c.Check(err, gc.ErrorMatches, "container sad_perlman has no health check")
+}
+
+func (s *waitSuite) TestWaitUntilRemoved(c *gc.C) {
+	client, fake := newClient(stateRunning, stateCreated)
+	fake.calls = append(fake.calls, runDockerCall{
+		err: "Error: No such object: sad_perlman",
+	})
+
+	err := client.WaitUntil(context.Background(), "sad_perlman", docker.ConditionRemoved)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(fake.index, gc.Equals, 3)
+}
+
+func (s *waitSuite) TestWaitUntilRunningNotFound(c *gc.C) {
+	client, fake := newClient()
+	fake.calls = []runDockerCall{{
+		err: "Error: No such object: sad_perlman",
+	}}
+
+	err := client.WaitUntil(context.Background(), "sad_perlman", docker.ConditionRunning)
+
+	c.Check(docker.IsNotFound(err), jc.IsTrue)
+}
+
+func (s *waitSuite) TestWaitUntilUnknownCondition(c *gc.C) {
+	client, _ := newClient(stateRunning)
+
+	err := client.WaitUntil(context.Background(), "sad_perlman", docker.WaitCondition("spam"))
+
+	c.Check(err, gc.ErrorMatches, `unknown wait condition "spam"`)
+}
+
+func (s *waitSuite) TestWaitUntilTimeout(c *gc.C) {
+	outs := make([]string, 1000)
+	for i := range outs {
+		outs[i] = stateCreated
+	}
+	client, _ := newClient(outs...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := client.WaitUntil(ctx, "sad_perlman", docker.ConditionRunning)
+
+	c.Check(err, gc.ErrorMatches, "waiting for container sad_perlman to be running timed out: context deadline exceeded")
+	c.Check(errors.Is(err, context.DeadlineExceeded), jc.IsTrue)
+}